@@ -0,0 +1,193 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Environment variables used to configure the service
+	addrEnv        = "SCHOOLS_ADDR"
+	storeEnv       = "SCHOOLS_STORE"
+	sqlitePathEnv  = "SCHOOLS_SQLITE_PATH"
+	postgresDSNEnv = "SCHOOLS_POSTGRES_DSN"
+	autoMigrateEnv = "SCHOOLS_AUTO_MIGRATE"
+	retentionEnv   = "SCHOOLS_TOMBSTONE_RETENTION"
+	purgeEnv       = "SCHOOLS_PURGE_INTERVAL"
+	cursorKeyEnv   = "SCHOOLS_CURSOR_SECRET"
+	validateEnv    = "SCHOOLS_VALIDATE_RESPONSES"
+	ifMatchEnv     = "SCHOOLS_REQUIRE_IF_MATCH"
+	idempotencyEnv = "SCHOOLS_IDEMPOTENCY_TTL"
+	leaseEnv       = "SCHOOLS_IDEMPOTENCY_LEASE"
+
+	addrDefault        = ":8080"
+	sqlitePathDefault  = "schools.db"
+	autoMigrateDefault = true
+	retentionDefault   = 30 * 24 * time.Hour
+	purgeDefault       = time.Hour
+	idempotencyDefault = 24 * time.Hour
+	leaseDefault       = time.Minute
+)
+
+const (
+	// StoreMemory keeps schools in memory. Changes are lost when the process
+	// exits.
+	StoreMemory = "memory"
+
+	// StoreSQLite persists schools to an SQLite database file.
+	StoreSQLite = "sqlite"
+
+	// StorePostgres persists schools to a PostgreSQL database.
+	StorePostgres = "postgres"
+)
+
+// Config holds the settings for the schools API service.
+type Config struct {
+	// Addr is the address the HTTP server listens on.
+	Addr string
+
+	// Store selects the backend used to hold schools.
+	Store string
+
+	// SQLitePath is the path to the database file used by the sqlite store.
+	SQLitePath string
+
+	// PostgresDSN is the connection string used by the postgres store.
+	PostgresDSN string
+
+	// AutoMigrate applies pending schema migrations when the service
+	// starts. When it is false migrations must be applied with the migrate
+	// command.
+	AutoMigrate bool
+
+	// TombstoneRetention is how long deleted schools can be restored before
+	// they are purged. Zero disables purging.
+	TombstoneRetention time.Duration
+
+	// PurgeInterval is how often expired tombstones are purged.
+	PurgeInterval time.Duration
+
+	// CursorSecret is the key used to sign pagination cursors. When it is
+	// empty a random key is used, so cursors stop working when the service
+	// restarts and are not accepted by other instances.
+	CursorSecret string
+
+	// ValidateResponses checks every response against the API
+	// specification and replaces those that do not match with a 500
+	// response. It slows down every request, so it is meant for testing.
+	ValidateResponses bool
+
+	// RequireIfMatch rejects requests that change a school without an
+	// If-Match header, so that clients cannot overwrite changes made since
+	// they read the school.
+	RequireIfMatch bool
+
+	// IdempotencyTTL is how long the response to a request with an
+	// Idempotency-Key header is kept and replayed to repeats of the
+	// request. Zero disables idempotency keys.
+	IdempotencyTTL time.Duration
+
+	// IdempotencyLease is how long a request with an Idempotency-Key header
+	// holds the key while it is in progress. Repeats of the request are
+	// turned away until it finishes or the lease runs out, so it should be
+	// longer than any request takes but short enough that a request lost
+	// to a crash does not block its retries for long.
+	IdempotencyLease time.Duration
+}
+
+// Load reads the configuration from the environment, applying defaults for
+// any settings that are not set.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Addr:         getEnv(addrEnv, addrDefault),
+		Store:        getEnv(storeEnv, StoreMemory),
+		SQLitePath:   getEnv(sqlitePathEnv, sqlitePathDefault),
+		PostgresDSN:  os.Getenv(postgresDSNEnv),
+		CursorSecret: os.Getenv(cursorKeyEnv),
+	}
+
+	switch cfg.Store {
+	case StoreMemory, StoreSQLite:
+	case StorePostgres:
+		if cfg.PostgresDSN == "" {
+			return nil, fmt.Errorf("%s is required when %s is %q", postgresDSNEnv, storeEnv, StorePostgres)
+		}
+	default:
+		return nil, fmt.Errorf("%s must be one of %q, %q or %q, got %q", storeEnv, StoreMemory, StoreSQLite, StorePostgres, cfg.Store)
+	}
+
+	autoMigrate, err := strconv.ParseBool(getEnv(autoMigrateEnv, strconv.FormatBool(autoMigrateDefault)))
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a boolean: %v", autoMigrateEnv, err)
+	}
+	cfg.AutoMigrate = autoMigrate
+
+	validateResponses, err := strconv.ParseBool(getEnv(validateEnv, "false"))
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a boolean: %v", validateEnv, err)
+	}
+	cfg.ValidateResponses = validateResponses
+
+	requireIfMatch, err := strconv.ParseBool(getEnv(ifMatchEnv, "false"))
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a boolean: %v", ifMatchEnv, err)
+	}
+	cfg.RequireIfMatch = requireIfMatch
+
+	cfg.TombstoneRetention, err = getDuration(retentionEnv, retentionDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.IdempotencyTTL, err = getDuration(idempotencyEnv, idempotencyDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.IdempotencyLease, err = getDuration(leaseEnv, leaseDefault)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.IdempotencyLease <= 0 {
+		return nil, fmt.Errorf("%s must be positive", leaseEnv)
+	}
+
+	cfg.PurgeInterval, err = getDuration(purgeEnv, purgeDefault)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PurgeInterval <= 0 {
+		return nil, fmt.Errorf("%s must be positive", purgeEnv)
+	}
+
+	return cfg, nil
+}
+
+// getEnv returns the value of the environment variable named by key or
+// fallback if the variable is not set or empty.
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getDuration parses the environment variable named by key as a
+// time.Duration, returning fallback if the variable is not set or empty.
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration: %v", key, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative", key)
+	}
+	return d, nil
+}
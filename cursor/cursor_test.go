@@ -0,0 +1,45 @@
+package cursor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+	want := Cursor{Sort: "-name", ID: 42, Name: "Université Laval"}
+
+	token := codec.Encode(want)
+	if strings.ContainsAny(token, "+/=?&") {
+		t.Errorf("Encode returned %q, which is not URL safe", token)
+	}
+
+	got, err := codec.Decode(token)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got != want {
+		t.Errorf("Decode returned %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeRejectsInvalidTokens(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+	token := codec.Encode(Cursor{Sort: "id", ID: 10})
+	payload := token[:strings.Index(token, ".")]
+	forged := NewCodec([]byte("other")).Encode(Cursor{Sort: "id", ID: 10})
+
+	tokens := map[string]string{
+		"empty":          "",
+		"unsigned":       payload,
+		"bad base64":     "!!!." + token[len(payload)+1:],
+		"wrong key":      forged,
+		"tampered":       NewCodec([]byte("secret")).Encode(Cursor{Sort: "id", ID: 11})[:len(payload)] + token[len(payload):],
+		"extra segments": token + ".x",
+	}
+	for name, token := range tokens {
+		if _, err := codec.Decode(token); err != ErrInvalid {
+			t.Errorf("Decode(%s) returned %v, want ErrInvalid", name, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// collator orders school names using the Unicode Collation Algorithm so that,
+// for example, "Universidad de Córdoba" sorts next to "Universidad de
+// Cordoba" rather than after every unaccented name. A Collator is not safe
+// for concurrent use, so it is guarded by a mutex.
+var collator = struct {
+	sync.Mutex
+	c   *collate.Collator
+	buf collate.Buffer
+}{
+	c: collate.New(language.Und),
+}
+
+// nameSortKey returns the collation key of name. Comparing the keys of two
+// names byte by byte orders the names the same way as the collator, which
+// lets the SQL stores sort by an indexed binary column.
+func nameSortKey(name string) []byte {
+	collator.Lock()
+	defer collator.Unlock()
+
+	key := collator.c.KeyFromString(&collator.buf, name)
+	collator.buf.Reset()
+	return append([]byte(nil), key...)
+}
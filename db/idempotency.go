@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// IdempotencyStore is implemented by stores that can hold the responses to
+// requests made with an idempotency key, so that a request that is retried
+// with the same key is answered with the stored response instead of being
+// carried out again.
+type IdempotencyStore interface {
+	// BeginRequest records that a request with key, whose method, path and
+	// body hash to fingerprint, has started. The record expires at
+	// expiresAt unless the request is completed first, so that a request
+	// that never finishes, for example because the process running it
+	// exits, only holds the key until then. If an unexpired record with key
+	// already exists it is returned instead and started is false.
+	BeginRequest(ctx context.Context, key, fingerprint string, expiresAt time.Time) (record *IdempotencyRecord, started bool, err error)
+
+	// CompleteRequest stores the response to the request started with key
+	// and keeps it until expiresAt.
+	CompleteRequest(ctx context.Context, key string, response StoredResponse, expiresAt time.Time) error
+
+	// AbandonRequest removes the record of the request started with key,
+	// so that it can be retried.
+	AbandonRequest(ctx context.Context, key string) error
+
+	// PurgeRequests removes the records that expired before now and returns
+	// the number removed.
+	PurgeRequests(ctx context.Context, now time.Time) (int, error)
+}
+
+// IdempotencyRecord is the record of a request made with an idempotency key.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	ExpiresAt   time.Time
+
+	// Response is nil while the request is in progress.
+	Response *StoredResponse
+}
+
+// StoredResponse is a response that is replayed to requests that repeat the
+// request it was sent for.
+type StoredResponse struct {
+	Status int
+	Header map[string][]string
+	Body   []byte
+}
+
+// PurgeExpiredRequests removes the expired records of requests made with an
+// idempotency key from store every interval until ctx is done, so it is meant
+// to be run in its own goroutine. Expired records are ignored whether or not
+// they have been purged; purging only reclaims their space.
+func PurgeExpiredRequests(ctx context.Context, store IdempotencyStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := store.PurgeRequests(ctx, time.Now()); err != nil {
+			log.Printf("unable to purge expired idempotency keys: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,584 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a SchoolStore that keeps the list of schools, including the
+// tombstones of deleted schools, in a slice ordered by id. It is safe for
+// concurrent use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	schools []School
+	nextID  int
+
+	// nameKeys holds the collation key of each school's name by id.
+	nameKeys map[int][]byte
+
+	// nameNorms holds the normalized name of each school by id.
+	nameNorms map[int]string
+
+	// names holds the ids of the schools with each normalized name, in
+	// ascending order. Only seeded schools can share a name.
+	names map[string][]int
+
+	// revision is counted up by every change to the schools.
+	revision Revision
+
+	// requests holds the records of requests made with an idempotency key,
+	// by key. It has its own lock so that requests do not wait for changes
+	// to the schools.
+	requestsMu sync.Mutex
+	requests   map[string]IdempotencyRecord
+}
+
+// NewMemoryStore returns a MemoryStore seeded with the schools in data.go. The
+// id of each seeded school is its index in the list.
+func NewMemoryStore() *MemoryStore {
+	now := time.Now().UTC()
+	s := &MemoryStore{
+		schools:   make([]School, len(schoolDB)),
+		nextID:    len(schoolDB),
+		nameKeys:  make(map[int][]byte, len(schoolDB)),
+		nameNorms: make(map[int]string, len(schoolDB)),
+		names:     make(map[string][]int, len(schoolDB)),
+		revision:  Revision{Epoch: newEpoch(), ModifiedAt: now},
+	}
+	for id, name := range schoolDB {
+		s.schools[id] = School{ID: id, Name: name, Version: 1, UpdatedAt: now}
+		s.setName(id, name)
+	}
+	return s
+}
+
+// find returns the index of the school with the specified id in the slice of
+// schools or -1 if there is no such school. The caller must hold s.mu.
+func (s *MemoryStore) find(id int) int {
+	idx := sort.Search(len(s.schools), func(i int) bool {
+		return s.schools[i].ID >= id
+	})
+	if idx < len(s.schools) && s.schools[idx].ID == id {
+		return idx
+	}
+	return -1
+}
+
+// findActive returns the index of the school with the specified id in the
+// slice of schools. If there is no such school a *NotFoundError is returned
+// and if it has been deleted a *GoneError is returned. The caller must hold
+// s.mu.
+func (s *MemoryStore) findActive(id int) (int, error) {
+	idx := s.find(id)
+	if idx < 0 {
+		return idx, &NotFoundError{ID: id}
+	}
+	if s.schools[idx].DeletedAt != nil {
+		return idx, &GoneError{ID: id}
+	}
+	return idx, nil
+}
+
+// checkName returns a *ConflictError if a school other than the one with the
+// specified id, including deleted schools that have not been purged, has the
+// same normalized name as name. The caller must hold s.mu.
+func (s *MemoryStore) checkName(name string, id int) error {
+	for _, other := range s.names[NormalizeName(name)] {
+		if other != id {
+			school := s.schools[s.find(other)]
+			return &ConflictError{ID: school.ID, Name: school.Name, Deleted: school.DeletedAt != nil}
+		}
+	}
+	return nil
+}
+
+// setName records the collation key and normalized name of the school with
+// the specified id. The caller must hold s.mu.
+func (s *MemoryStore) setName(id int, name string) {
+	s.removeName(id)
+	norm := NormalizeName(name)
+	s.nameKeys[id] = nameSortKey(name)
+	s.nameNorms[id] = norm
+
+	ids := s.names[norm]
+	i := sort.SearchInts(ids, id)
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	s.names[norm] = ids
+}
+
+// removeName forgets the name of the school with the specified id. The caller
+// must hold s.mu.
+func (s *MemoryStore) removeName(id int) {
+	norm, ok := s.nameNorms[id]
+	if !ok {
+		return
+	}
+	delete(s.nameKeys, id)
+	delete(s.nameNorms, id)
+
+	ids := s.names[norm]
+	if i := sort.SearchInts(ids, id); i < len(ids) && ids[i] == id {
+		ids = append(ids[:i], ids[i+1:]...)
+	}
+	if len(ids) == 0 {
+		delete(s.names, norm)
+	} else {
+		s.names[norm] = ids
+	}
+}
+
+// changed counts a change to the schools in the revision of the store and
+// returns the time of the change. The caller must hold s.mu.
+func (s *MemoryStore) changed() time.Time {
+	now := time.Now().UTC()
+	s.revision.Number++
+	s.revision.ModifiedAt = now
+	return now
+}
+
+// less reports whether the school at index i in the slice of schools sorts
+// before the one at index j in ascending order. The caller must hold s.mu.
+func (s *MemoryStore) less(field string, i, j int) bool {
+	b := s.schools[j]
+	return s.compare(field, i, s.nameKeys[b.ID], b.ID) < 0
+}
+
+// compare returns -1, 0 or 1 depending on whether the school at index i in the
+// slice of schools sorts before, at or after the school with the specified name
+// key and id in ascending order. The caller must hold s.mu.
+func (s *MemoryStore) compare(field string, i int, nameKey []byte, id int) int {
+	a := s.schools[i]
+	if field == SortByName {
+		if c := bytes.Compare(s.nameKeys[a.ID], nameKey); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a.ID < id:
+		return -1
+	case a.ID > id:
+		return 1
+	}
+	return 0
+}
+
+// GetSchools returns a SchoolsResult struct based on the specified offset and
+// limit into the active or deleted schools that match the filter, in the
+// requested order. The returned schools are copies, so later changes to the
+// store do not affect them.
+func (s *MemoryStore) GetSchools(ctx context.Context, opts ListOptions) (SchoolsResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getSchools(opts), nil
+}
+
+// getSchools implements GetSchools. The caller must hold s.mu.
+func (s *MemoryStore) getSchools(opts ListOptions) SchoolsResult {
+	// Find the matching schools, which are already in ascending id order
+	var matches []int
+	for i, school := range s.schools {
+		if (school.DeletedAt != nil) != opts.Deleted || !opts.Filter.Match(school.Name) {
+			continue
+		}
+		if opts.ExternalID != "" && school.ExternalID != opts.ExternalID {
+			continue
+		}
+		matches = append(matches, i)
+	}
+
+	if opts.Sort.Field != "" && opts.Sort.Field != SortByID {
+		sort.Slice(matches, func(i, j int) bool {
+			return s.less(opts.Sort.Field, matches[i], matches[j])
+		})
+	}
+	if opts.Sort.Desc {
+		for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+			matches[i], matches[j] = matches[j], matches[i]
+		}
+	}
+
+	start := opts.Offset
+	if pos := opts.After; pos != nil {
+		var nameKey []byte
+		if opts.Sort.Field == SortByName {
+			nameKey = nameSortKey(pos.Name)
+		}
+		start = sort.Search(len(matches), func(i int) bool {
+			c := s.compare(opts.Sort.Field, matches[i], nameKey, pos.ID)
+			if opts.Sort.Desc {
+				return c < 0
+			}
+			return c > 0
+		})
+	}
+
+	result := SchoolsResult{Total: len(matches)}
+	for i := start; i < len(matches) && len(result.Schools) < opts.Limit; i++ {
+		result.Schools = append(result.Schools, s.schools[matches[i]])
+	}
+	return result
+}
+
+// GetSchool returns the school with the specified id. If the school is not
+// found nil and a *NotFoundError will be returned.
+func (s *MemoryStore) GetSchool(ctx context.Context, id int) (*School, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getSchool(id)
+}
+
+// getSchool implements GetSchool. The caller must hold s.mu.
+func (s *MemoryStore) getSchool(id int) (*School, error) {
+	idx, err := s.findActive(id)
+	if err != nil {
+		return nil, err
+	}
+	school := s.schools[idx]
+	return &school, nil
+}
+
+// AddSchool assigns the next unused id to school and adds it to the end of the
+// slice of schools.
+func (s *MemoryStore) AddSchool(ctx context.Context, school School) (*School, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addSchool(school)
+}
+
+// addSchool implements AddSchool. The caller must hold s.mu.
+func (s *MemoryStore) addSchool(school School) (*School, error) {
+	if err := s.checkName(school.Name, -1); err != nil {
+		return nil, err
+	}
+	school.ID = s.nextID
+	school.DeletedAt = nil
+	school.Version = 1
+	school.UpdatedAt = s.changed()
+	s.nextID++
+	s.schools = append(s.schools, school)
+	s.setName(school.ID, school.Name)
+	return &school, nil
+}
+
+// UpdateSchool replaces the school with the same id as school. If there is no
+// such school a *NotFoundError will be returned. The name is only checked for
+// conflicts when its normalized form changes.
+func (s *MemoryStore) UpdateSchool(ctx context.Context, school School) (*School, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateSchool(school)
+}
+
+// updateSchool implements UpdateSchool. The caller must hold s.mu.
+func (s *MemoryStore) updateSchool(school School) (*School, error) {
+	idx, err := s.findActive(school.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(&s.schools[idx], school.Version); err != nil {
+		return nil, err
+	}
+	if NormalizeName(school.Name) != s.nameNorms[school.ID] {
+		if err := s.checkName(school.Name, school.ID); err != nil {
+			return nil, err
+		}
+	}
+	school.DeletedAt = nil
+	school.Version = s.schools[idx].Version + 1
+	school.UpdatedAt = s.changed()
+	s.schools[idx] = school
+	s.setName(school.ID, school.Name)
+	return &school, nil
+}
+
+// DeleteSchool marks the school with the specified id as deleted. If there is
+// no such school a *NotFoundError will be returned.
+func (s *MemoryStore) DeleteSchool(ctx context.Context, id int, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteSchool(id, version)
+}
+
+// deleteSchool implements DeleteSchool. The caller must hold s.mu.
+func (s *MemoryStore) deleteSchool(id int, version int64) error {
+	idx, err := s.findActive(id)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(&s.schools[idx], version); err != nil {
+		return err
+	}
+	now := s.changed()
+	s.schools[idx].DeletedAt = &now
+	s.schools[idx].Version++
+	s.schools[idx].UpdatedAt = now
+	return nil
+}
+
+// RestoreSchool clears the deleted mark from the school with the specified
+// id. If there is no such school a *NotFoundError will be returned.
+func (s *MemoryStore) RestoreSchool(ctx context.Context, id int) (*School, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.restoreSchool(id)
+}
+
+// restoreSchool implements RestoreSchool. The caller must hold s.mu.
+func (s *MemoryStore) restoreSchool(id int) (*School, error) {
+	idx := s.find(id)
+	if idx < 0 {
+		return nil, &NotFoundError{ID: id}
+	}
+	if s.schools[idx].DeletedAt != nil {
+		s.schools[idx].DeletedAt = nil
+		s.schools[idx].Version++
+		s.schools[idx].UpdatedAt = s.changed()
+	}
+	school := s.schools[idx]
+	return &school, nil
+}
+
+// PurgeSchools removes the schools deleted before deletedBefore from the slice
+// of schools.
+func (s *MemoryStore) PurgeSchools(ctx context.Context, deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purgeSchools(deletedBefore), nil
+}
+
+// purgeSchools implements PurgeSchools. The caller must hold s.mu.
+func (s *MemoryStore) purgeSchools(deletedBefore time.Time) int {
+	kept := s.schools[:0]
+	for _, school := range s.schools {
+		if purgeable(school, deletedBefore) {
+			s.removeName(school.ID)
+		} else {
+			kept = append(kept, school)
+		}
+	}
+	purged := len(s.schools) - len(kept)
+	s.schools = kept
+	if purged > 0 {
+		s.changed()
+	}
+	return purged
+}
+
+// purgeable reports whether school was deleted before deletedBefore.
+func purgeable(school School, deletedBefore time.Time) bool {
+	return school.DeletedAt != nil && school.DeletedAt.Before(deletedBefore)
+}
+
+// InTransaction calls fn with a store that changes the schools in place and
+// keeps the state of each school before its first change, which is put back
+// if fn fails. The store is locked until fn returns, so other callers wait
+// for the transaction to finish and never see its changes before then.
+func (s *MemoryStore) InTransaction(ctx context.Context, fn func(tx SchoolStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memoryTx{
+		store:    s,
+		saved:    make(map[int]*School),
+		nextID:   s.nextID,
+		revision: s.revision,
+	}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+// Revision returns the current revision of the schools in the store.
+func (s *MemoryStore) Revision(ctx context.Context) (Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.revision, nil
+}
+
+// BeginRequest records the start of a request made with key unless there is
+// an unexpired record of another request with key, which is returned instead.
+func (s *MemoryStore) BeginRequest(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*IdempotencyRecord, bool, error) {
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+
+	if record, ok := s.requests[key]; ok && record.ExpiresAt.After(time.Now()) {
+		return &record, false, nil
+	}
+	if s.requests == nil {
+		s.requests = make(map[string]IdempotencyRecord)
+	}
+	record := IdempotencyRecord{Key: key, Fingerprint: fingerprint, ExpiresAt: expiresAt}
+	s.requests[key] = record
+	return &record, true, nil
+}
+
+// CompleteRequest stores the response to the request started with key until
+// expiresAt.
+func (s *MemoryStore) CompleteRequest(ctx context.Context, key string, response StoredResponse, expiresAt time.Time) error {
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+
+	if record, ok := s.requests[key]; ok {
+		record.Response = &response
+		record.ExpiresAt = expiresAt
+		s.requests[key] = record
+	}
+	return nil
+}
+
+// AbandonRequest removes the record of the request started with key.
+func (s *MemoryStore) AbandonRequest(ctx context.Context, key string) error {
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+
+	delete(s.requests, key)
+	return nil
+}
+
+// PurgeRequests removes the records of requests that expired before now.
+func (s *MemoryStore) PurgeRequests(ctx context.Context, now time.Time) (int, error) {
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+
+	var purged int
+	for key, record := range s.requests {
+		if !record.ExpiresAt.After(now) {
+			delete(s.requests, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// memoryTx is the SchoolStore passed to the function run by
+// MemoryStore.InTransaction. It is only used while the store is locked.
+type memoryTx struct {
+	store *MemoryStore
+
+	// saved holds a copy of each school changed by the transaction as it was
+	// before the transaction, by id, or nil if the school was added by it.
+	saved map[int]*School
+
+	// nextID and revision are those of the store before the transaction.
+	nextID   int
+	revision Revision
+}
+
+// save keeps the state of the school with the specified id unless it has
+// already been changed by the transaction.
+func (tx *memoryTx) save(id int) {
+	if _, ok := tx.saved[id]; ok {
+		return
+	}
+	var saved *School
+	if idx := tx.store.find(id); idx >= 0 {
+		school := tx.store.schools[idx]
+		saved = &school
+	}
+	tx.saved[id] = saved
+}
+
+// rollback puts back the saved schools, removing those added by the
+// transaction.
+func (tx *memoryTx) rollback() {
+	s := tx.store
+	for id, saved := range tx.saved {
+		idx := s.find(id)
+		switch {
+		case saved == nil && idx >= 0:
+			s.schools = append(s.schools[:idx], s.schools[idx+1:]...)
+			s.removeName(id)
+		case saved != nil && idx >= 0:
+			s.schools[idx] = *saved
+			s.setName(id, saved.Name)
+		case saved != nil:
+			idx = sort.Search(len(s.schools), func(i int) bool {
+				return s.schools[i].ID >= id
+			})
+			s.schools = append(s.schools, School{})
+			copy(s.schools[idx+1:], s.schools[idx:])
+			s.schools[idx] = *saved
+			s.setName(id, saved.Name)
+		}
+	}
+	s.nextID, s.revision = tx.nextID, tx.revision
+}
+
+func (tx *memoryTx) GetSchools(ctx context.Context, opts ListOptions) (SchoolsResult, error) {
+	return tx.store.getSchools(opts), nil
+}
+
+func (tx *memoryTx) GetSchool(ctx context.Context, id int) (*School, error) {
+	return tx.store.getSchool(id)
+}
+
+func (tx *memoryTx) AddSchool(ctx context.Context, school School) (*School, error) {
+	tx.save(tx.store.nextID)
+	return tx.store.addSchool(school)
+}
+
+func (tx *memoryTx) UpdateSchool(ctx context.Context, school School) (*School, error) {
+	tx.save(school.ID)
+	return tx.store.updateSchool(school)
+}
+
+func (tx *memoryTx) DeleteSchool(ctx context.Context, id int, version int64) error {
+	tx.save(id)
+	return tx.store.deleteSchool(id, version)
+}
+
+func (tx *memoryTx) RestoreSchool(ctx context.Context, id int) (*School, error) {
+	tx.save(id)
+	return tx.store.restoreSchool(id)
+}
+
+func (tx *memoryTx) PurgeSchools(ctx context.Context, deletedBefore time.Time) (int, error) {
+	for _, school := range tx.store.schools {
+		if purgeable(school, deletedBefore) {
+			tx.save(school.ID)
+		}
+	}
+	return tx.store.purgeSchools(deletedBefore), nil
+}
+
+// InTransaction calls fn with a nested transaction, whose changes are undone
+// if fn fails and otherwise become part of tx.
+func (tx *memoryTx) InTransaction(ctx context.Context, fn func(tx SchoolStore) error) error {
+	nested := &memoryTx{
+		store:    tx.store,
+		saved:    make(map[int]*School),
+		nextID:   tx.store.nextID,
+		revision: tx.store.revision,
+	}
+	if err := fn(nested); err != nil {
+		nested.rollback()
+		return err
+	}
+
+	// The schools that tx had not changed yet are as they were before tx
+	for id, saved := range nested.saved {
+		if _, ok := tx.saved[id]; !ok {
+			tx.saved[id] = saved
+		}
+	}
+	return nil
+}
+
+func (tx *memoryTx) Revision(ctx context.Context) (Revision, error) {
+	return tx.store.revision, nil
+}
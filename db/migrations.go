@@ -0,0 +1,439 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migrationLockID is the key of the Postgres advisory lock held while
+// migrations run so that several instances starting at once do not race.
+const migrationLockID = 7325061
+
+// seedBatchSize is the number of schools inserted per statement when seeding.
+const seedBatchSize = 500
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// migration is a single forward-only change to the database schema. Once a
+// migration has been released it must never be changed; add a new one
+// instead.
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, tx *sql.Tx, d dialect) error
+}
+
+// migrations is the ordered list of schema migrations. Versions must be
+// sequential.
+var migrations = []migration{
+	{1, "create schools table", createSchoolsTable},
+	{2, "seed schools", seedSchools},
+	{3, "never reuse school ids", autoincrementSchoolIDs},
+	{4, "add schools.deleted_at", addSchoolsDeletedAt},
+	{5, "add schools.name_key", addSchoolsNameKey},
+	{6, "add school location, type, website and identifiers", addSchoolDetails},
+	{7, "index schools.external_id", indexSchoolsExternalID},
+	{8, "create schools_meta table", createSchoolsMetaTable},
+	{9, "add schools.name_norm", addSchoolsNameNorm},
+	{10, "add schools.version", addSchoolsVersion},
+	{11, "add schools.updated_at and schools_meta.updated_at", addUpdatedAt},
+	{12, "create idempotency_keys table", createIdempotencyKeysTable},
+}
+
+// createSchoolsTable creates the schools table. The table may already exist
+// in SQLite databases created before migrations were introduced.
+func createSchoolsTable(ctx context.Context, tx *sql.Tx, d dialect) error {
+	var stmt string
+	switch d {
+	case dialectPostgres:
+		stmt = `CREATE TABLE schools (
+			id   SERIAL PRIMARY KEY,
+			name TEXT NOT NULL
+		)`
+	default:
+		stmt = `CREATE TABLE IF NOT EXISTS schools (
+			id   INTEGER PRIMARY KEY,
+			name TEXT NOT NULL
+		)`
+	}
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
+
+// seedSchools loads the schools in data.go in to an empty schools table. The
+// id of each seeded school is its index in the list.
+func seedSchools(ctx context.Context, tx *sql.Tx, d dialect) error {
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schools").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for start := 0; start < len(schoolDB); start += seedBatchSize {
+		end := start + seedBatchSize
+		if end > len(schoolDB) {
+			end = len(schoolDB)
+		}
+
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, 2*(end-start))
+		for id := start; id < end; id++ {
+			values = append(values, "(?, ?)")
+			args = append(args, id, schoolDB[id])
+		}
+
+		query := d.rebind("INSERT INTO schools (id, name) VALUES " + strings.Join(values, ", "))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	if d == dialectPostgres {
+		// Explicit ids do not advance the serial sequence, so move it past
+		// the seeded schools.
+		_, err := tx.ExecContext(ctx, "SELECT setval(pg_get_serial_sequence('schools', 'id'), (SELECT MAX(id) FROM schools))")
+		return err
+	}
+	return nil
+}
+
+// autoincrementSchoolIDs makes sure that the id of a school is never handed
+// out again, even after the school with the highest id is removed. Postgres
+// sequences already behave this way but a plain SQLite INTEGER PRIMARY KEY
+// reuses the largest id, so the SQLite table is rebuilt with AUTOINCREMENT.
+func autoincrementSchoolIDs(ctx context.Context, tx *sql.Tx, d dialect) error {
+	if d == dialectPostgres {
+		return nil
+	}
+
+	stmts := []string{
+		`CREATE TABLE schools_autoincrement (
+			id   INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL
+		)`,
+		"INSERT INTO schools_autoincrement (id, name) SELECT id, name FROM schools",
+		"DROP TABLE schools",
+		"ALTER TABLE schools_autoincrement RENAME TO schools",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSchoolsDeletedAt adds the column used to mark a school as soft deleted.
+func addSchoolsDeletedAt(ctx context.Context, tx *sql.Tx, d dialect) error {
+	_, err := tx.ExecContext(ctx, "ALTER TABLE schools ADD COLUMN deleted_at TIMESTAMP")
+	return err
+}
+
+// addSchoolsNameKey adds and fills in the column holding the collation key of
+// each school's name, which is used to sort schools by name.
+func addSchoolsNameKey(ctx context.Context, tx *sql.Tx, d dialect) error {
+	colType := "BLOB"
+	if d == dialectPostgres {
+		colType = "BYTEA"
+	}
+	stmts := []string{
+		"ALTER TABLE schools ADD COLUMN name_key " + colType,
+		"CREATE INDEX schools_name_key ON schools (name_key, id)",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	// Read every name before updating since a transaction can only run one
+	// statement at a time.
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM schools")
+	if err != nil {
+		return err
+	}
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, d.rebind("UPDATE schools SET name_key = ? WHERE id = ?"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, name := range names {
+		if _, err := stmt.ExecContext(ctx, nameSortKey(name), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSchoolDetails adds the optional columns describing a school. Missing
+// values are stored as empty strings rather than NULL so that they scan
+// straight into the fields of a School.
+func addSchoolDetails(ctx context.Context, tx *sql.Tx, d dialect) error {
+	columns := []string{
+		"city",
+		"state",
+		"postal_code",
+		"country",
+		"institution_type",
+		"level",
+		"website",
+		"external_id",
+	}
+	for _, column := range columns {
+		stmt := "ALTER TABLE schools ADD COLUMN " + column + " TEXT NOT NULL DEFAULT ''"
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexSchoolsExternalID indexes the external id used to match the rows of an
+// import to existing schools.
+func indexSchoolsExternalID(ctx context.Context, tx *sql.Tx, d dialect) error {
+	_, err := tx.ExecContext(ctx, "CREATE INDEX schools_external_id ON schools (external_id)")
+	return err
+}
+
+// createSchoolsMetaTable creates the single row table holding the revision of
+// the schools, which every change to the schools table counts up.
+func createSchoolsMetaTable(ctx context.Context, tx *sql.Tx, d dialect) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE schools_meta (
+		epoch    TEXT NOT NULL,
+		revision BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, d.rebind("INSERT INTO schools_meta (epoch, revision) VALUES (?, 0)"), newEpoch())
+	return err
+}
+
+// addSchoolsNameNorm adds, fills in and indexes the column holding the
+// normalized form of each school's name, which is used to reject duplicate
+// names. The index is not unique since some of the seeded schools already
+// share a normalized name.
+func addSchoolsNameNorm(ctx context.Context, tx *sql.Tx, d dialect) error {
+	stmts := []string{
+		"ALTER TABLE schools ADD COLUMN name_norm TEXT NOT NULL DEFAULT ''",
+		"CREATE INDEX schools_name_norm ON schools (name_norm)",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	// Read every name before updating since a transaction can only run one
+	// statement at a time.
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM schools")
+	if err != nil {
+		return err
+	}
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, d.rebind("UPDATE schools SET name_norm = ? WHERE id = ?"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, name := range names {
+		if _, err := stmt.ExecContext(ctx, NormalizeName(name), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSchoolsVersion adds the column counting the changes made to each school.
+// Existing schools start at version 1, like newly added ones.
+func addSchoolsVersion(ctx context.Context, tx *sql.Tx, d dialect) error {
+	_, err := tx.ExecContext(ctx, "ALTER TABLE schools ADD COLUMN version BIGINT NOT NULL DEFAULT 1")
+	return err
+}
+
+// addUpdatedAt adds the columns holding when each school and the schools as a
+// whole were last changed. As the times of past changes are not known, they
+// are set to the time of the migration.
+func addUpdatedAt(ctx context.Context, tx *sql.Tx, d dialect) error {
+	now := time.Now().UTC()
+	for _, table := range []string{"schools", "schools_meta"} {
+		if _, err := tx.ExecContext(ctx, "ALTER TABLE "+table+" ADD COLUMN updated_at TIMESTAMP"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, d.rebind("UPDATE "+table+" SET updated_at = ?"), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIdempotencyKeysTable creates the table holding the records of requests
+// made with an idempotency key. The status, header and body of the response
+// are NULL while the request is in progress.
+func createIdempotencyKeysTable(ctx context.Context, tx *sql.Tx, d dialect) error {
+	binary := "BLOB"
+	if d == dialectPostgres {
+		binary = "BYTEA"
+	}
+	stmts := []string{
+		`CREATE TABLE idempotency_keys (
+			idempotency_key TEXT PRIMARY KEY,
+			fingerprint     TEXT NOT NULL,
+			expires_at      TIMESTAMP NOT NULL,
+			status          INTEGER,
+			header          TEXT,
+			body            ` + binary + `
+		)`,
+		"CREATE INDEX idempotency_keys_expires_at ON idempotency_keys (expires_at)",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the version of the last migration applied to the
+// database or 0 if no migrations have been applied.
+func schemaVersion(ctx context.Context, q queryer, d dialect) (int, error) {
+	var query string
+	switch d {
+	case dialectPostgres:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'"
+	default:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	}
+
+	var exists int
+	if err := q.QueryRowContext(ctx, query).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+
+	var version int
+	err := q.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// checkSchemaVersion returns an error if the database has been migrated by a
+// newer build that knows about migrations this one does not.
+func checkSchemaVersion(version int) error {
+	latest := migrations[len(migrations)-1].version
+	if version > latest {
+		return fmt.Errorf("database schema version %d is newer than the latest known version %d", version, latest)
+	}
+	return nil
+}
+
+// pendingMigrations returns the number of migrations that have not been
+// applied to the database.
+func pendingMigrations(ctx context.Context, sqlDB *sql.DB, d dialect) (int, error) {
+	version, err := schemaVersion(ctx, sqlDB, d)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkSchemaVersion(version); err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if m.version > version {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// migrate applies all pending migrations in a single transaction and returns
+// the number of migrations applied.
+func migrate(ctx context.Context, sqlDB *sql.DB, d dialect) (int, error) {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if d == dialectPostgres {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at  TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := schemaVersion(ctx, tx, d)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkSchemaVersion(version); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	insert := d.rebind("INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)")
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := m.up(ctx, tx, d); err != nil {
+			return 0, fmt.Errorf("migration %d (%s) failed: %v", m.version, m.description, err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, m.version, m.description, time.Now().UTC()); err != nil {
+			return 0, err
+		}
+		applied++
+	}
+
+	return applied, tx.Commit()
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeName returns the form of name used to decide whether two schools
+// have the same name. Letters are folded to lower case and everything but
+// letters and digits, such as whitespace and punctuation, is dropped, so that
+// "St. Mary's College" and "st marys  college" have the same normalized name.
+func NormalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.Is(unicode.Mn, r):
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
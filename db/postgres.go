@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	// Register the "postgres" database/sql driver.
+	_ "github.com/lib/pq"
+)
+
+const (
+	// How long to wait for the Postgres server to accept connections.
+	postgresConnectTimeout = 30 * time.Second
+	postgresRetryInterval  = time.Second
+)
+
+// NewPostgresStore connects to the Postgres database described by dsn. Call
+// Migrate on the returned store to create the schema and seed it with the
+// schools in data.go.
+func NewPostgresStore(dsn string) (*SQLStore, error) {
+	sqlDB, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// The database is often started alongside the service, so give it some
+	// time to come up before giving up.
+	ctx, cancel := context.WithTimeout(context.Background(), postgresConnectTimeout)
+	defer cancel()
+	for {
+		err = sqlDB.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			sqlDB.Close()
+			return nil, err
+		case <-time.After(postgresRetryInterval):
+		}
+	}
+
+	return &SQLStore{db: sqlDB, dialect: dialectPostgres}, nil
+}
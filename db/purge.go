@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeDeletedSchools permanently removes schools from store once they have
+// been deleted for longer than retention. It checks for expired tombstones
+// every interval until ctx is done, so it is meant to be run in its own
+// goroutine.
+func PurgeDeletedSchools(ctx context.Context, store SchoolStore, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := store.PurgeSchools(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("unable to purge deleted schools: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted schools", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// newEpoch returns a random epoch for a new store's revisions.
+func newEpoch() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// The system's random number generator is broken, which nothing
+		// can recover from
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,609 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialect identifies the flavor of SQL spoken by the database behind a
+// SQLStore.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// rebind rewrites the ? placeholders in query to the placeholder style used
+// by the dialect.
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$")
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// readTxOptions returns the options for a read-only transaction that sees a
+// single consistent snapshot of the database. SQLite transactions always do.
+func (d dialect) readTxOptions() *sql.TxOptions {
+	if d == dialectPostgres {
+		return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	return nil
+}
+
+// SQLStore is a SchoolStore backed by a SQL database. Use NewSQLiteStore or
+// NewPostgresStore to create one. It is safe for concurrent use.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+
+	// tx is set on the stores passed to the function given to
+	// InTransaction, which run every statement in the transaction.
+	tx *sql.Tx
+
+	// savepoints is the number of nested transactions the store is bound
+	// to, each of which is a savepoint of tx.
+	savepoints int
+}
+
+// conn returns the transaction the store is bound to, or the database if it
+// is not bound to one.
+func (s *SQLStore) conn() queryer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// Close closes the underlying database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Migrate applies any pending schema migrations and returns the number of
+// migrations that were applied.
+func (s *SQLStore) Migrate(ctx context.Context) (int, error) {
+	return migrate(ctx, s.db, s.dialect)
+}
+
+// PendingMigrations returns the number of schema migrations that have not
+// been applied to the database yet.
+func (s *SQLStore) PendingMigrations(ctx context.Context) (int, error) {
+	return pendingMigrations(ctx, s.db, s.dialect)
+}
+
+// likeEscaper escapes the wildcard characters of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// schoolColumns are the columns selected by scanSchool, in order.
+const schoolColumns = "id, name, city, state, postal_code, country, institution_type, level, website, external_id, deleted_at, version, updated_at"
+
+// schoolFieldColumns are the columns written from the fields of a School by
+// AddSchool and UpdateSchool, in the order of the values from schoolValues.
+var schoolFieldColumns = []string{"name", "name_key", "name_norm", "city", "state", "postal_code", "country", "institution_type", "level", "website", "external_id", "updated_at"}
+
+// schoolValues returns the values written to schoolFieldColumns for school.
+func schoolValues(school School) []interface{} {
+	return []interface{}{
+		school.Name,
+		nameSortKey(school.Name),
+		NormalizeName(school.Name),
+		school.City,
+		school.State,
+		school.PostalCode,
+		school.Country,
+		school.Type,
+		school.Level,
+		school.Website,
+		school.ExternalID,
+		school.UpdatedAt,
+	}
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSchool reads a row of schoolColumns into school.
+func scanSchool(row rowScanner, school *School) error {
+	return row.Scan(
+		&school.ID,
+		&school.Name,
+		&school.City,
+		&school.State,
+		&school.PostalCode,
+		&school.Country,
+		&school.Type,
+		&school.Level,
+		&school.Website,
+		&school.ExternalID,
+		&school.DeletedAt,
+		&school.Version,
+		&school.UpdatedAt,
+	)
+}
+
+// listWhere returns the WHERE clause and its arguments that select the
+// schools matched by opts.
+func listWhere(opts ListOptions) (string, []interface{}) {
+	conds := []string{"deleted_at IS NULL"}
+	if opts.Deleted {
+		conds[0] = "deleted_at IS NOT NULL"
+	}
+
+	var args []interface{}
+	f := opts.Filter
+	if f.Contains != "" {
+		conds = append(conds, `LOWER(name) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(f.Contains))+"%")
+	}
+	if f.Prefix != "" {
+		conds = append(conds, `LOWER(name) LIKE ? ESCAPE '\'`)
+		args = append(args, likeEscaper.Replace(strings.ToLower(f.Prefix))+"%")
+	}
+	if f.Exact != "" {
+		conds = append(conds, "LOWER(name) = ?")
+		args = append(args, strings.ToLower(f.Exact))
+	}
+	if opts.ExternalID != "" {
+		conds = append(conds, "external_id = ?")
+		args = append(args, opts.ExternalID)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// listOrderBy returns the ORDER BY clause for the sort in opts.
+func listOrderBy(opts ListOptions) string {
+	dir := "ASC"
+	if opts.Sort.Desc {
+		dir = "DESC"
+	}
+
+	switch opts.Sort.Field {
+	case SortByName:
+		return "name_key " + dir + ", id " + dir
+	default:
+		return "id " + dir
+	}
+}
+
+// listAfter returns the condition and its arguments that select the schools
+// sorting after opts.After, which must not be nil.
+func listAfter(opts ListOptions) (string, []interface{}) {
+	op := ">"
+	if opts.Sort.Desc {
+		op = "<"
+	}
+
+	pos := opts.After
+	switch opts.Sort.Field {
+	case SortByName:
+		key := nameSortKey(pos.Name)
+		return "(name_key " + op + " ? OR (name_key = ? AND id " + op + " ?))", []interface{}{key, key, pos.ID}
+	default:
+		return "id " + op + " ?", []interface{}{pos.ID}
+	}
+}
+
+// GetSchools returns a SchoolsResult struct based on the specified offset and
+// limit into the active or deleted schools that match the filter, in the
+// requested order. The total and the page of schools are read from the same
+// snapshot of the database.
+func (s *SQLStore) GetSchools(ctx context.Context, opts ListOptions) (SchoolsResult, error) {
+	where, args := listWhere(opts)
+
+	var result SchoolsResult
+	q := s.conn()
+	if s.tx == nil {
+		tx, err := s.db.BeginTx(ctx, s.dialect.readTxOptions())
+		if err != nil {
+			return result, err
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	count := s.dialect.rebind("SELECT COUNT(*) FROM schools WHERE " + where)
+	err := q.QueryRowContext(ctx, count, args...).Scan(&result.Total)
+	if err != nil {
+		return result, err
+	}
+
+	offset := opts.Offset
+	if opts.After != nil {
+		after, afterArgs := listAfter(opts)
+		where += " AND " + after
+		args = append(args, afterArgs...)
+		offset = 0
+	}
+
+	query := s.dialect.rebind("SELECT " + schoolColumns + " FROM schools WHERE " + where + " ORDER BY " + listOrderBy(opts) + " LIMIT ? OFFSET ?")
+	rows, err := q.QueryContext(ctx, query, append(args, opts.Limit, offset)...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var school School
+		if err := scanSchool(rows, &school); err != nil {
+			return result, err
+		}
+		result.Schools = append(result.Schools, school)
+	}
+	return result, rows.Err()
+}
+
+// getSchool returns the school with the specified id whether or not it has
+// been deleted. If the school is not found nil and a *NotFoundError will be
+// returned.
+func (s *SQLStore) getSchool(ctx context.Context, id int) (*School, error) {
+	var school School
+	query := s.dialect.rebind("SELECT " + schoolColumns + " FROM schools WHERE id = ?")
+	err := scanSchool(s.conn().QueryRowContext(ctx, query, id), &school)
+	if err == sql.ErrNoRows {
+		return nil, &NotFoundError{ID: id}
+	} else if err != nil {
+		return nil, err
+	}
+	return &school, nil
+}
+
+// missingSchoolError is called when a statement that only affects active
+// schools did not find the school with the specified id. It returns a
+// *GoneError if the school has been deleted and a *NotFoundError otherwise.
+func (s *SQLStore) missingSchoolError(ctx context.Context, id int) error {
+	school, err := s.getSchool(ctx, id)
+	if err != nil {
+		return err
+	}
+	if school.DeletedAt != nil {
+		return &GoneError{ID: id}
+	}
+	return &NotFoundError{ID: id}
+}
+
+// GetSchool returns the school with the specified id. If the school is not
+// found nil and a *NotFoundError will be returned.
+func (s *SQLStore) GetSchool(ctx context.Context, id int) (*School, error) {
+	school, err := s.getSchool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if school.DeletedAt != nil {
+		return nil, &GoneError{ID: id}
+	}
+	return school, nil
+}
+
+// checkName returns a *ConflictError if a school other than the one with the
+// specified id, including deleted schools that have not been purged, has the
+// same normalized name as name. It must be called from a function passed to
+// write, which keeps another writer from taking the name before the caller's
+// change is committed.
+func (s *SQLStore) checkName(ctx context.Context, name string, id int) error {
+	var conflict ConflictError
+	var deletedAt *time.Time
+	query := s.dialect.rebind("SELECT id, name, deleted_at FROM schools WHERE name_norm = ? AND id <> ? ORDER BY id LIMIT 1")
+	err := s.conn().QueryRowContext(ctx, query, NormalizeName(name), id).Scan(&conflict.ID, &conflict.Name, &deletedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	conflict.Deleted = deletedAt != nil
+	return &conflict
+}
+
+// write runs fn in a transaction, creating one unless the store is already
+// bound to one, after counting a change in the revision of the store. The
+// revision is counted first so that concurrent writers to a Postgres database
+// wait for each other on the row holding it. fn is passed the time of the
+// change.
+func (s *SQLStore) write(ctx context.Context, fn func(tx *SQLStore, now time.Time) error) error {
+	now := time.Now().UTC()
+	return s.InTransaction(ctx, func(tx SchoolStore) error {
+		sqlTx := tx.(*SQLStore)
+		query := s.dialect.rebind("UPDATE schools_meta SET revision = revision + 1, updated_at = ?")
+		if _, err := sqlTx.tx.ExecContext(ctx, query, now); err != nil {
+			return err
+		}
+		return fn(sqlTx, now)
+	})
+}
+
+// AddSchool inserts school and returns it with the id assigned by the
+// database.
+func (s *SQLStore) AddSchool(ctx context.Context, school School) (*School, error) {
+	school.DeletedAt = nil
+	school.Version = 1
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(schoolFieldColumns)), ", ")
+	query := s.dialect.rebind("INSERT INTO schools (" + strings.Join(schoolFieldColumns, ", ") + ") VALUES (" + placeholders + ") RETURNING id")
+	err := s.write(ctx, func(tx *SQLStore, now time.Time) error {
+		if err := tx.checkName(ctx, school.Name, -1); err != nil {
+			return err
+		}
+		school.UpdatedAt = now
+		return tx.tx.QueryRowContext(ctx, query, schoolValues(school)...).Scan(&school.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &school, nil
+}
+
+// UpdateSchool replaces the school with the same id as school. If there is no
+// such school a *NotFoundError will be returned. The name is only checked for
+// conflicts when its normalized form changes.
+func (s *SQLStore) UpdateSchool(ctx context.Context, school School) (*School, error) {
+	school.DeletedAt = nil
+	query := s.dialect.rebind("UPDATE schools SET " + strings.Join(schoolFieldColumns, " = ?, ") + " = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL")
+	err := s.write(ctx, func(tx *SQLStore, now time.Time) error {
+		current, err := tx.GetSchool(ctx, school.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(current, school.Version); err != nil {
+			return err
+		}
+		school.Version = current.Version + 1
+		school.UpdatedAt = now
+		if NormalizeName(school.Name) != NormalizeName(current.Name) {
+			if err := tx.checkName(ctx, school.Name, school.ID); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.tx.ExecContext(ctx, query, append(schoolValues(school), school.ID)...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return tx.missingSchoolError(ctx, school.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &school, nil
+}
+
+// DeleteSchool marks the school with the specified id as deleted. If there is
+// no such school a *NotFoundError will be returned.
+func (s *SQLStore) DeleteSchool(ctx context.Context, id int, version int64) error {
+	query := s.dialect.rebind("UPDATE schools SET deleted_at = ?, version = version + 1, updated_at = ? WHERE id = ? AND deleted_at IS NULL")
+	return s.write(ctx, func(tx *SQLStore, now time.Time) error {
+		if version != 0 {
+			current, err := tx.GetSchool(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := checkVersion(current, version); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.tx.ExecContext(ctx, query, now, now, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return tx.missingSchoolError(ctx, id)
+		}
+		return nil
+	})
+}
+
+// RestoreSchool clears the deleted mark from the school with the specified
+// id. If there is no such school a *NotFoundError will be returned.
+func (s *SQLStore) RestoreSchool(ctx context.Context, id int) (*School, error) {
+	var school *School
+	query := s.dialect.rebind("UPDATE schools SET deleted_at = NULL, version = version + 1, updated_at = ? WHERE id = ?")
+	err := s.InTransaction(ctx, func(tx SchoolStore) error {
+		sqlTx := tx.(*SQLStore)
+		var err error
+		school, err = sqlTx.getSchool(ctx, id)
+		if err != nil || school.DeletedAt == nil {
+			return err
+		}
+
+		// Only count a change in the revision when the school is deleted
+		return sqlTx.write(ctx, func(tx *SQLStore, now time.Time) error {
+			if _, err := tx.tx.ExecContext(ctx, query, now, id); err != nil {
+				return err
+			}
+			school, err = tx.getSchool(ctx, id)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return school, nil
+}
+
+// PurgeSchools deletes the rows of schools deleted before deletedBefore.
+func (s *SQLStore) PurgeSchools(ctx context.Context, deletedBefore time.Time) (int, error) {
+	where := " FROM schools WHERE deleted_at IS NOT NULL AND deleted_at < ?"
+	deletedBefore = deletedBefore.UTC()
+
+	// Only count a change in the revision when there is something to purge
+	var expired int
+	err := s.conn().QueryRowContext(ctx, s.dialect.rebind("SELECT COUNT(*)"+where), deletedBefore).Scan(&expired)
+	if err != nil || expired == 0 {
+		return 0, err
+	}
+
+	var purged int64
+	err = s.write(ctx, func(tx *SQLStore, now time.Time) error {
+		res, err := tx.tx.ExecContext(ctx, s.dialect.rebind("DELETE"+where), deletedBefore)
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	return int(purged), err
+}
+
+// Revision returns the current revision of the schools in the database.
+func (s *SQLStore) Revision(ctx context.Context) (Revision, error) {
+	var rev Revision
+	err := s.conn().QueryRowContext(ctx, "SELECT epoch, revision, updated_at FROM schools_meta").Scan(&rev.Epoch, &rev.Number, &rev.ModifiedAt)
+	return rev, err
+}
+
+// InTransaction calls fn with a store bound to a new transaction, which is
+// committed if fn succeeds and rolled back otherwise. Stores that are already
+// bound to a transaction start a nested one with a savepoint instead.
+func (s *SQLStore) InTransaction(ctx context.Context, fn func(tx SchoolStore) error) error {
+	if s.tx != nil {
+		return s.inSavepoint(ctx, fn)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLStore{db: s.db, dialect: s.dialect, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// inSavepoint calls fn with a store bound to the transaction of s after
+// setting a savepoint, which is rolled back to if fn fails, so that only the
+// changes made by fn are undone and the transaction can carry on.
+func (s *SQLStore) inSavepoint(ctx context.Context, fn func(tx SchoolStore) error) error {
+	name := fmt.Sprintf("nested_%d", s.savepoints+1)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	if err := fn(&SQLStore{db: s.db, dialect: s.dialect, tx: s.tx, savepoints: s.savepoints + 1}); err != nil {
+		if _, rollbackErr := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return rollbackErr
+		}
+		if _, releaseErr := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); releaseErr != nil {
+			return releaseErr
+		}
+		return err
+	}
+	_, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// BeginRequest inserts the record of a request made with key, first removing
+// any expired record with key. If an unexpired record with key exists it is
+// returned instead.
+func (s *SQLStore) BeginRequest(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*IdempotencyRecord, bool, error) {
+	record := &IdempotencyRecord{Key: key, Fingerprint: fingerprint, ExpiresAt: expiresAt.UTC()}
+	for {
+		_, err := s.conn().ExecContext(ctx, s.dialect.rebind("DELETE FROM idempotency_keys WHERE idempotency_key = ? AND expires_at <= ?"), key, time.Now().UTC())
+		if err != nil {
+			return nil, false, err
+		}
+
+		query := s.dialect.rebind("INSERT INTO idempotency_keys (idempotency_key, fingerprint, expires_at) VALUES (?, ?, ?) ON CONFLICT (idempotency_key) DO NOTHING")
+		res, err := s.conn().ExecContext(ctx, query, key, fingerprint, record.ExpiresAt)
+		if err != nil {
+			return nil, false, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, false, err
+		}
+		if n == 1 {
+			return record, true, nil
+		}
+
+		existing, err := s.getRequest(ctx, key)
+		if err == sql.ErrNoRows {
+			// The record was abandoned or expired since the insert, so
+			// try again.
+			continue
+		}
+		return existing, false, err
+	}
+}
+
+// getRequest returns the record of the request made with key.
+func (s *SQLStore) getRequest(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	record := IdempotencyRecord{Key: key}
+	var status sql.NullInt64
+	var header sql.NullString
+	var body []byte
+	query := s.dialect.rebind("SELECT fingerprint, expires_at, status, header, body FROM idempotency_keys WHERE idempotency_key = ?")
+	err := s.conn().QueryRowContext(ctx, query, key).Scan(&record.Fingerprint, &record.ExpiresAt, &status, &header, &body)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Valid {
+		return &record, nil
+	}
+
+	record.Response = &StoredResponse{Status: int(status.Int64), Body: body}
+	if err := json.Unmarshal([]byte(header.String), &record.Response.Header); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CompleteRequest stores the response to the request started with key until
+// expiresAt.
+func (s *SQLStore) CompleteRequest(ctx context.Context, key string, response StoredResponse, expiresAt time.Time) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	query := s.dialect.rebind("UPDATE idempotency_keys SET status = ?, header = ?, body = ?, expires_at = ? WHERE idempotency_key = ?")
+	_, err = s.conn().ExecContext(ctx, query, response.Status, string(header), response.Body, expiresAt.UTC(), key)
+	return err
+}
+
+// AbandonRequest removes the record of the request started with key.
+func (s *SQLStore) AbandonRequest(ctx context.Context, key string) error {
+	_, err := s.conn().ExecContext(ctx, s.dialect.rebind("DELETE FROM idempotency_keys WHERE idempotency_key = ?"), key)
+	return err
+}
+
+// PurgeRequests removes the records of requests that expired before now.
+func (s *SQLStore) PurgeRequests(ctx context.Context, now time.Time) (int, error) {
+	res, err := s.conn().ExecContext(ctx, s.dialect.rebind("DELETE FROM idempotency_keys WHERE expires_at <= ?"), now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	return int(purged), err
+}
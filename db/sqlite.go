@@ -0,0 +1,23 @@
+package db
+
+import (
+	"database/sql"
+
+	// Register the pure Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+// NewSQLiteStore opens (or creates) the SQLite database at path. Call Migrate
+// on the returned store to create the schema and seed it with the schools in
+// data.go.
+func NewSQLiteStore(path string) (*SQLStore, error) {
+	sqlDB, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows a single writer at a time, so share one connection
+	// instead of letting concurrent writers fail with SQLITE_BUSY.
+	sqlDB.SetMaxOpenConns(1)
+
+	return &SQLStore{db: sqlDB, dialect: dialectSQLite}, nil
+}
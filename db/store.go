@@ -0,0 +1,336 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchoolStore is implemented by every backend that can hold the list of
+// schools. Handlers only talk to a SchoolStore so that the backing storage can
+// be swapped out (or faked in tests) without touching handler code.
+type SchoolStore interface {
+	// GetSchools returns a SchoolsResult based on the limit and offset in
+	// opts into the collection of schools.
+	GetSchools(ctx context.Context, opts ListOptions) (SchoolsResult, error)
+
+	// GetSchool returns the school with the specified id. A *NotFoundError
+	// is returned if there is no such school and a *GoneError if it has been
+	// deleted.
+	GetSchool(ctx context.Context, id int) (*School, error)
+
+	// AddSchool adds a new school and returns it with its newly assigned
+	// id. The ID field of school is ignored. A *ConflictError is returned if
+	// another school has the same normalized name.
+	AddSchool(ctx context.Context, school School) (*School, error)
+
+	// UpdateSchool replaces the school with the same id as school and
+	// returns the stored school. A *NotFoundError is returned if there is no
+	// such school and a *GoneError if it has been deleted. A *ConflictError
+	// is returned if the name is changed to the normalized name of another
+	// school. If school.Version is not zero the school is only replaced if
+	// it is still at that version, otherwise a *VersionMismatchError is
+	// returned.
+	UpdateSchool(ctx context.Context, school School) (*School, error)
+
+	// DeleteSchool soft deletes the school with the specified id, leaving a
+	// tombstone that can be restored until it is purged. The ids of the
+	// remaining schools are not affected. A *NotFoundError is returned if
+	// there is no such school and a *GoneError if it is already deleted. If
+	// version is not zero the school is only deleted if it is at that
+	// version, otherwise a *VersionMismatchError is returned.
+	DeleteSchool(ctx context.Context, id int, version int64) error
+
+	// RestoreSchool undoes the deletion of the school with the specified id
+	// and returns it. Restoring a school that is not deleted has no effect. A
+	// *NotFoundError is returned if there is no such school.
+	RestoreSchool(ctx context.Context, id int) (*School, error)
+
+	// PurgeSchools permanently removes schools that were deleted before the
+	// specified time and returns the number of schools removed.
+	PurgeSchools(ctx context.Context, deletedBefore time.Time) (int, error)
+
+	// InTransaction calls fn with a SchoolStore whose changes are applied
+	// atomically: they are all kept if fn returns nil and all discarded if
+	// it returns an error, which InTransaction then returns. Other callers
+	// never see some of the changes without the rest. fn must only use the
+	// store it is given and must not keep it after returning. Called on a
+	// store that is bound to a transaction, InTransaction starts a nested
+	// one, whose changes are discarded if fn fails and otherwise kept or
+	// discarded along with the rest of the enclosing transaction.
+	InTransaction(ctx context.Context, fn func(tx SchoolStore) error) error
+
+	// Revision returns the current revision of the schools in the store.
+	Revision(ctx context.Context) (Revision, error)
+}
+
+// Revision identifies a state of the schools in a store. Every change to a
+// school is counted, so two reads that return the same Revision saw the same
+// schools.
+type Revision struct {
+	// Epoch is chosen at random when the store is created so that the
+	// revisions of different stores, or of a store that has been recreated,
+	// are never equal.
+	Epoch string
+
+	// Number counts the changes made to the schools in the store.
+	Number int64
+
+	// ModifiedAt is when the schools were last changed, or when the store
+	// was created if they have not been changed since.
+	ModifiedAt time.Time
+}
+
+// School is a single school record held in a SchoolStore. IDs are assigned by
+// the store when a school is added and are never changed or reused, so
+// clients may cache them indefinitely.
+type School struct {
+	ID   int
+	Name string
+
+	// Location of the school. Country is an ISO 3166-1 alpha-2 code.
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+
+	// Type is one of the institution types and Level one of the levels
+	// below.
+	Type  string
+	Level string
+
+	// Website is the URL of the school's home page.
+	Website string
+
+	// ExternalID identifies the school in an external dataset, such as its
+	// IPEDS Unit ID.
+	ExternalID string
+
+	// DeletedAt is set when the school has been soft deleted.
+	DeletedAt *time.Time
+
+	// UpdatedAt is when the school was added or last changed, including
+	// being deleted or restored.
+	UpdatedAt time.Time
+
+	// Version counts the changes made to the school. It is 1 when the school
+	// is added and is counted up by every update, delete and restore, so a
+	// client can tell whether the school has changed since it read it.
+	Version int64
+}
+
+// Institution types
+const (
+	TypePublic    = "public"
+	TypePrivate   = "private"
+	TypeForProfit = "for-profit"
+)
+
+// Levels
+const (
+	LevelTwoYear  = "2-year"
+	LevelFourYear = "4-year"
+)
+
+// ListOptions selects the page of schools returned by GetSchools.
+type ListOptions struct {
+	Limit  int
+	Offset int
+
+	// Deleted lists the deleted schools instead of the active ones.
+	Deleted bool
+
+	// Filter limits the schools to the ones with matching names. The filter
+	// is applied before the offset and limit.
+	Filter NameFilter
+
+	// ExternalID, when not empty, limits the schools to the ones with this
+	// external id.
+	ExternalID string
+
+	// Sort orders the schools before the offset and limit are applied. The
+	// zero value orders schools by id.
+	Sort Sort
+
+	// After, when set, starts the page with the first school that sorts after
+	// the position instead of at Offset. Unlike an offset, the position is not
+	// shifted by schools added or removed before it. Total still counts every
+	// matching school.
+	After *Position
+}
+
+// Position is the place of a school in the sort order of a list of schools,
+// made up of its sort key and id.
+type Position struct {
+	ID int
+
+	// Name is only used when sorting by name.
+	Name string
+}
+
+// Fields that schools can be sorted by
+const (
+	SortByID   = "id"
+	SortByName = "name"
+)
+
+// sortFields is the set of fields that schools can be sorted by.
+var sortFields = []string{SortByID, SortByName}
+
+// Sort orders schools by a field. Schools with equal values for the field are
+// ordered by id, in the same direction.
+type Sort struct {
+	// Field is the field to sort by. An empty Field sorts by id.
+	Field string
+
+	// Desc sorts in descending instead of ascending order.
+	Desc bool
+}
+
+// String returns the sort in the form accepted by ParseSort.
+func (s Sort) String() string {
+	field := s.Field
+	if field == "" {
+		field = SortByID
+	}
+	if s.Desc {
+		return "-" + field
+	}
+	return field
+}
+
+// ParseSort parses a field name to sort by, optionally prefixed with "-" to
+// sort in descending order.
+func ParseSort(value string) (Sort, error) {
+	sort := Sort{Field: value}
+	if strings.HasPrefix(value, "-") {
+		sort = Sort{Field: value[1:], Desc: true}
+	}
+
+	for _, field := range sortFields {
+		if sort.Field == field {
+			return sort, nil
+		}
+	}
+	return Sort{}, fmt.Errorf("cannot sort by %q", value)
+}
+
+// SortValues returns the values accepted by ParseSort.
+func SortValues() []string {
+	values := make([]string, 0, 2*len(sortFields))
+	for _, field := range sortFields {
+		values = append(values, field, "-"+field)
+	}
+	return values
+}
+
+// NameFilter matches schools by name. All matching is case-insensitive and
+// empty fields are ignored. A school must match every non-empty field.
+type NameFilter struct {
+	// Contains matches names that contain the string.
+	Contains string
+
+	// Prefix matches names that start with the string.
+	Prefix string
+
+	// Exact matches names that are equal to the string.
+	Exact string
+}
+
+// Match reports whether name is matched by the filter.
+func (f NameFilter) Match(name string) bool {
+	name = strings.ToLower(name)
+	if f.Contains != "" && !strings.Contains(name, strings.ToLower(f.Contains)) {
+		return false
+	}
+	if f.Prefix != "" && !strings.HasPrefix(name, strings.ToLower(f.Prefix)) {
+		return false
+	}
+	if f.Exact != "" && name != strings.ToLower(f.Exact) {
+		return false
+	}
+	return true
+}
+
+// SchoolsResult is a struct used to represent a page of schools from the
+// database that includes the total count of schools matching the
+// ListOptions.
+type SchoolsResult struct {
+	Schools []School
+	Total   int
+}
+
+// NotFoundError is returned by a SchoolStore when the requested school does
+// not exist.
+type NotFoundError struct {
+	ID int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("School with id %d not found", e.ID)
+}
+
+// GoneError is returned by a SchoolStore when the requested school has been
+// deleted.
+type GoneError struct {
+	ID int
+}
+
+func (e *GoneError) Error() string {
+	return fmt.Sprintf("School with id %d has been deleted", e.ID)
+}
+
+// ConflictError is returned by a SchoolStore when a change would give a
+// school the same name as another school. Names are compared in the form
+// returned by NormalizeName. Deleted schools keep their names until they are
+// purged so that they can always be restored. Schools that already share a
+// name, such as some of the seeded schools, may keep it.
+type ConflictError struct {
+	// ID and Name are the id and name of the school that has the name.
+	ID   int
+	Name string
+
+	// Deleted is set if the school that has the name has been deleted.
+	Deleted bool
+}
+
+func (e *ConflictError) Error() string {
+	if e.Deleted {
+		return fmt.Sprintf("Deleted school with id %d already has the name %q", e.ID, e.Name)
+	}
+	return fmt.Sprintf("School with id %d already has the name %q", e.ID, e.Name)
+}
+
+// VersionMismatchError is returned by a SchoolStore when a change is made on
+// the condition that a school is at a version it is not at, because it has
+// been changed since the caller read it.
+type VersionMismatchError struct {
+	ID int
+
+	// Version is the version the caller expected the school to be at.
+	Version int64
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("School with id %d has been changed since version %d", e.ID, e.Version)
+}
+
+// checkVersion returns a *VersionMismatchError if version is not zero and is
+// not the version of school.
+func checkVersion(school *School, version int64) error {
+	if version != 0 && school.Version != version {
+		return &VersionMismatchError{ID: school.ID, Version: version}
+	}
+	return nil
+}
+
+// Migrator is implemented by stores whose schema is managed with versioned
+// migrations.
+type Migrator interface {
+	// Migrate applies any pending migrations and returns the number applied.
+	Migrate(ctx context.Context) (int, error)
+
+	// PendingMigrations returns the number of migrations not yet applied.
+	PendingMigrations(ctx context.Context) (int, error)
+}
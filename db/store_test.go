@@ -0,0 +1,956 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// postgresDSNEnv names the connection string of a Postgres server to run the
+// store tests against. The Postgres store is skipped when it is unset.
+const postgresDSNEnv = "SCHOOLS_POSTGRES_DSN"
+
+// newTestStores returns a freshly seeded instance of every SchoolStore that
+// can run without external services, plus the Postgres store when
+// SCHOOLS_POSTGRES_DSN is set.
+func newTestStores(t *testing.T) map[string]SchoolStore {
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "schools.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	if _, err := sqlite.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	stores := map[string]SchoolStore{
+		"memory": NewMemoryStore(),
+		"sqlite": sqlite,
+	}
+	if dsn := os.Getenv(postgresDSNEnv); dsn != "" {
+		stores["postgres"] = newTestPostgresStore(t, dsn)
+	}
+	return stores
+}
+
+// newTestPostgresStore returns a seeded Postgres store in a schema of its own,
+// dropped when the test ends, so tests don't see each other's schools.
+func newTestPostgresStore(t *testing.T, dsn string) *SQLStore {
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("schools_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("CREATE SCHEMA: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("DROP SCHEMA: %v", err)
+		}
+	})
+
+	store, err := NewPostgresStore(withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatalf("NewPostgresStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if _, err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return store
+}
+
+// withSearchPath adds a search_path run-time parameter to dsn, which may be a
+// URL or a list of key=value settings.
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect dialect
+		query   string
+		want    string
+	}{
+		{dialectSQLite, "SELECT * FROM schools WHERE id = ? AND name = ?", "SELECT * FROM schools WHERE id = ? AND name = ?"},
+		{dialectPostgres, "SELECT * FROM schools WHERE id = ? AND name = ?", "SELECT * FROM schools WHERE id = $1 AND name = $2"},
+		{dialectPostgres, "UPDATE schools SET name = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL", "UPDATE schools SET name = $1, version = version + 1 WHERE id = $2 AND deleted_at IS NULL"},
+		{dialectPostgres, "SELECT COUNT(*) FROM schools", "SELECT COUNT(*) FROM schools"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+// TestConcurrentWrites adds, updates and lists schools from many goroutines
+// at once. Run it with -race to check the stores for data races.
+func TestConcurrentWrites(t *testing.T) {
+	const (
+		writers   = 8
+		perWriter = 25
+	)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			before, err := store.GetSchools(ctx, ListOptions{Limit: 1})
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+
+			var (
+				wg  sync.WaitGroup
+				mu  sync.Mutex
+				ids = make(map[int]string)
+			)
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < perWriter; i++ {
+						added, err := store.AddSchool(ctx, School{Name: fmt.Sprintf("School %d-%d", w, i)})
+						if err != nil {
+							t.Errorf("AddSchool: %v", err)
+							return
+						}
+
+						name := fmt.Sprintf("Updated School %d-%d", w, i)
+						if _, err := store.UpdateSchool(ctx, School{ID: added.ID, Name: name}); err != nil {
+							t.Errorf("UpdateSchool(%d): %v", added.ID, err)
+							return
+						}
+
+						offset := (w*perWriter + i) * 10
+						if _, err := store.GetSchools(ctx, ListOptions{Limit: 100, Offset: offset}); err != nil {
+							t.Errorf("GetSchools: %v", err)
+							return
+						}
+
+						mu.Lock()
+						if _, ok := ids[added.ID]; ok {
+							t.Errorf("id %d was assigned twice", added.ID)
+						}
+						ids[added.ID] = name
+						mu.Unlock()
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			after, err := store.GetSchools(ctx, ListOptions{Limit: 1})
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+			if want := before.Total + writers*perWriter; after.Total != want {
+				t.Errorf("total is %d, want %d", after.Total, want)
+			}
+
+			for id, name := range ids {
+				school, err := store.GetSchool(ctx, id)
+				if err != nil {
+					t.Errorf("GetSchool(%d): %v", id, err)
+					continue
+				}
+				if school.Name != name {
+					t.Errorf("school %d is named %q, want %q", id, school.Name, name)
+				}
+			}
+		})
+	}
+}
+
+// TestConcurrentDeletes deletes, restores and lists schools from many
+// goroutines at once.
+func TestConcurrentDeletes(t *testing.T) {
+	const workers = 8
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for id := w; id < 200; id += workers {
+						if err := store.DeleteSchool(ctx, id, 0); err != nil {
+							t.Errorf("DeleteSchool(%d): %v", id, err)
+							return
+						}
+						if _, err := store.GetSchools(ctx, ListOptions{Limit: 100, Deleted: true}); err != nil {
+							t.Errorf("GetSchools: %v", err)
+							return
+						}
+						if _, err := store.RestoreSchool(ctx, id); err != nil {
+							t.Errorf("RestoreSchool(%d): %v", id, err)
+							return
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			deleted, err := store.GetSchools(ctx, ListOptions{Limit: 100, Deleted: true})
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+			if deleted.Total != 0 {
+				t.Errorf("%d schools are still deleted, want 0", deleted.Total)
+			}
+		})
+	}
+}
+
+// TestGetSchoolsSnapshot checks that a page of schools is not changed by
+// writes made after it was returned.
+func TestGetSchoolsSnapshot(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			page, err := store.GetSchools(ctx, ListOptions{Limit: 10})
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+			first := page.Schools[0]
+
+			if _, err := store.UpdateSchool(ctx, School{ID: first.ID, Name: "Renamed"}); err != nil {
+				t.Fatalf("UpdateSchool: %v", err)
+			}
+			if err := store.DeleteSchool(ctx, page.Schools[1].ID, 0); err != nil {
+				t.Fatalf("DeleteSchool: %v", err)
+			}
+
+			if page.Schools[0].Name != first.Name {
+				t.Errorf("page changed from %q to %q after update", first.Name, page.Schools[0].Name)
+			}
+			if page.Schools[1].DeletedAt != nil {
+				t.Errorf("page changed after delete")
+			}
+		})
+	}
+}
+
+// TestGetSchoolsFilter checks that every store applies name filters the same
+// way, before pagination.
+func TestGetSchoolsFilter(t *testing.T) {
+	filters := []NameFilter{
+		{Contains: "AUBURN"},
+		{Prefix: "university of alabama"},
+		{Exact: "auburn university"},
+		{Contains: "college", Prefix: "central"},
+		{Contains: "100%"},
+	}
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, f := range filters {
+				all, err := store.GetSchools(ctx, ListOptions{Limit: 1000, Filter: f})
+				if err != nil {
+					t.Fatalf("GetSchools(%+v): %v", f, err)
+				}
+
+				want := 0
+				for _, schoolName := range schoolDB {
+					if f.Match(schoolName) {
+						want++
+					}
+				}
+				if all.Total != want || len(all.Schools) != want {
+					t.Errorf("filter %+v matched %d (%d returned), want %d", f, all.Total, len(all.Schools), want)
+				}
+
+				page, err := store.GetSchools(ctx, ListOptions{Limit: 1, Offset: 1, Filter: f})
+				if err != nil {
+					t.Fatalf("GetSchools(%+v): %v", f, err)
+				}
+				if page.Total != all.Total {
+					t.Errorf("filter %+v total changed from %d to %d with pagination", f, all.Total, page.Total)
+				}
+				if want > 1 && page.Schools[0] != all.Schools[1] {
+					t.Errorf("filter %+v second school is %+v, want %+v", f, page.Schools[0], all.Schools[1])
+				}
+			}
+		})
+	}
+}
+
+// TestGetSchoolsSort checks that every store sorts schools the same way and
+// that descending order is the exact reverse of ascending order.
+func TestGetSchoolsSort(t *testing.T) {
+	stores := newTestStores(t)
+	ctx := context.Background()
+
+	// Accented names should sort next to their unaccented forms, not after
+	// every unaccented name
+	for name, store := range stores {
+		if _, err := store.AddSchool(ctx, School{Name: "Université Laval"}); err != nil {
+			t.Fatalf("%s AddSchool: %v", name, err)
+		}
+	}
+
+	for _, value := range SortValues() {
+		sort, err := ParseSort(value)
+		if err != nil {
+			t.Fatalf("ParseSort(%q): %v", value, err)
+		}
+
+		var want []School
+		for name, store := range stores {
+			got, err := store.GetSchools(ctx, ListOptions{Limit: 10000, Sort: sort})
+			if err != nil {
+				t.Fatalf("%s GetSchools(%s): %v", name, value, err)
+			}
+			if want == nil {
+				want = got.Schools
+				continue
+			}
+			if len(got.Schools) != len(want) {
+				t.Fatalf("%s returned %d schools sorted by %s, want %d", name, len(got.Schools), value, len(want))
+			}
+			for i := range want {
+				if got.Schools[i].ID != want[i].ID {
+					t.Errorf("%s school %d sorted by %s is %q, want %q", name, i, value, got.Schools[i].Name, want[i].Name)
+					break
+				}
+			}
+		}
+	}
+
+	for name, store := range stores {
+		asc, _ := store.GetSchools(ctx, ListOptions{Limit: 10000, Sort: Sort{Field: SortByName}})
+		desc, _ := store.GetSchools(ctx, ListOptions{Limit: 10000, Sort: Sort{Field: SortByName, Desc: true}})
+		for i := range asc.Schools {
+			if asc.Schools[i].ID != desc.Schools[len(desc.Schools)-1-i].ID {
+				t.Errorf("%s descending name order is not the reverse of ascending order", name)
+				break
+			}
+		}
+
+		for i, school := range asc.Schools {
+			if school.Name != "Université Laval" {
+				continue
+			}
+			prev, next := asc.Schools[i-1].Name, asc.Schools[i+1].Name
+			if !strings.HasPrefix(prev, "Univ") || !strings.HasPrefix(next, "Univ") {
+				t.Errorf("%s sorted %q between %q and %q", name, school.Name, prev, next)
+			}
+		}
+	}
+
+	if _, err := ParseSort("-city"); err == nil {
+		t.Errorf("ParseSort(%q) did not return an error", "-city")
+	}
+}
+
+// TestGetSchoolsAfter checks that paging with positions visits the same
+// schools as a single page, and that schools added before a position do not
+// shift the pages after it.
+func TestGetSchoolsAfter(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			filter := NameFilter{Prefix: "university of"}
+			for _, value := range SortValues() {
+				sort, _ := ParseSort(value)
+				all, err := store.GetSchools(ctx, ListOptions{Limit: 1000, Filter: filter, Sort: sort})
+				if err != nil {
+					t.Fatalf("GetSchools(%s): %v", value, err)
+				}
+
+				var got []School
+				opts := ListOptions{Limit: 7, Filter: filter, Sort: sort}
+				for {
+					page, err := store.GetSchools(ctx, opts)
+					if err != nil {
+						t.Fatalf("GetSchools(%s) after %+v: %v", value, opts.After, err)
+					}
+					if page.Total != all.Total {
+						t.Fatalf("sort %s total is %d after %+v, want %d", value, page.Total, opts.After, all.Total)
+					}
+					got = append(got, page.Schools...)
+					if len(page.Schools) < opts.Limit {
+						break
+					}
+					last := page.Schools[len(page.Schools)-1]
+					opts.After = &Position{ID: last.ID, Name: last.Name}
+				}
+
+				if len(got) != len(all.Schools) {
+					t.Fatalf("sort %s paged through %d schools, want %d", value, len(got), len(all.Schools))
+				}
+				for i := range got {
+					if got[i] != all.Schools[i] {
+						t.Errorf("sort %s school %d is %+v, want %+v", value, i, got[i], all.Schools[i])
+						break
+					}
+				}
+			}
+
+			// A school added before the position is not returned and does
+			// not repeat the last school of the previous page
+			opts := ListOptions{Limit: 3, Filter: filter, Sort: Sort{Field: SortByName}}
+			first, err := store.GetSchools(ctx, opts)
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+			last := first.Schools[2]
+			if _, err := store.AddSchool(ctx, School{Name: "University of Aaa"}); err != nil {
+				t.Fatalf("AddSchool: %v", err)
+			}
+			opts.After = &Position{ID: last.ID, Name: last.Name}
+			next, err := store.GetSchools(ctx, opts)
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+			want, err := store.GetSchools(ctx, ListOptions{Limit: 3, Offset: 4, Filter: filter, Sort: opts.Sort})
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+			if next.Schools[0] != want.Schools[0] {
+				t.Errorf("school after %q is %+v, want %+v", last.Name, next.Schools[0], want.Schools[0])
+			}
+		})
+	}
+}
+
+func TestSchoolDetailsRoundTrip(t *testing.T) {
+	school := School{
+		Name:       "Round Trip University",
+		City:       "Auburn",
+		State:      "AL",
+		PostalCode: "36849",
+		Country:    "US",
+		Type:       TypePublic,
+		Level:      LevelFourYear,
+		Website:    "https://www.auburn.edu",
+		ExternalID: "100858",
+	}
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			added, err := store.AddSchool(ctx, school)
+			if err != nil {
+				t.Fatalf("AddSchool: %v", err)
+			}
+			want := school
+			want.ID = added.ID
+			want.Version = 1
+			want.UpdatedAt = added.UpdatedAt
+
+			got, err := store.GetSchool(ctx, added.ID)
+			if err != nil {
+				t.Fatalf("GetSchool: %v", err)
+			}
+			if *got != want {
+				t.Errorf("GetSchool returned %+v, want %+v", *got, want)
+			}
+
+			want.Type = TypePrivate
+			want.Website = ""
+			updated, err := store.UpdateSchool(ctx, want)
+			if err != nil {
+				t.Fatalf("UpdateSchool: %v", err)
+			}
+			want.Version++
+			want.UpdatedAt = updated.UpdatedAt
+			page, err := store.GetSchools(ctx, ListOptions{Limit: 1, Filter: NameFilter{Exact: want.Name}})
+			if err != nil {
+				t.Fatalf("GetSchools: %v", err)
+			}
+			if len(page.Schools) != 1 || page.Schools[0] != want {
+				t.Errorf("GetSchools returned %+v, want %+v", page.Schools, want)
+			}
+		})
+	}
+}
+
+func TestInTransaction(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			errRollback := errors.New("rollback")
+			var added *School
+			err := store.InTransaction(ctx, func(tx SchoolStore) error {
+				var err error
+				added, err = tx.AddSchool(ctx, School{Name: "Rolled Back College"})
+				if err != nil {
+					return err
+				}
+				if err := tx.DeleteSchool(ctx, 0, 0); err != nil {
+					return err
+				}
+
+				// Changes are visible inside the transaction
+				if _, err := tx.GetSchool(ctx, added.ID); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if err != errRollback {
+				t.Fatalf("InTransaction returned %v, want %v", err, errRollback)
+			}
+			if _, err := store.GetSchool(ctx, added.ID); err == nil {
+				t.Errorf("school added in a rolled back transaction exists")
+			}
+			if _, err := store.GetSchool(ctx, 0); err != nil {
+				t.Errorf("school deleted in a rolled back transaction is gone: %v", err)
+			}
+
+			err = store.InTransaction(ctx, func(tx SchoolStore) error {
+				added, err = tx.AddSchool(ctx, School{Name: "Committed College"})
+				return err
+			})
+			if err != nil {
+				t.Fatalf("InTransaction: %v", err)
+			}
+			if _, err := store.GetSchool(ctx, added.ID); err != nil {
+				t.Errorf("school added in a committed transaction is missing: %v", err)
+			}
+		})
+	}
+}
+
+func TestNestedTransaction(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			before, err := store.Revision(ctx)
+			if err != nil {
+				t.Fatalf("Revision: %v", err)
+			}
+
+			// The nested transaction fails, which the enclosing one
+			// ignores, so only the changes made outside of it are kept
+			errRollback := errors.New("rollback")
+			var kept, dropped *School
+			err = store.InTransaction(ctx, func(tx SchoolStore) error {
+				var err error
+				kept, err = tx.AddSchool(ctx, School{Name: "Outer Transaction College"})
+				if err != nil {
+					return err
+				}
+				nestedErr := tx.InTransaction(ctx, func(nested SchoolStore) error {
+					dropped, err = nested.AddSchool(ctx, School{Name: "Nested Transaction College"})
+					if err != nil {
+						return err
+					}
+					if _, err := nested.UpdateSchool(ctx, School{ID: kept.ID, Name: "Renamed Outer College"}); err != nil {
+						return err
+					}
+					return errRollback
+				})
+				if nestedErr != errRollback {
+					t.Errorf("nested InTransaction returned %v, want %v", nestedErr, errRollback)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("InTransaction: %v", err)
+			}
+
+			if school, err := store.GetSchool(ctx, kept.ID); err != nil || school.Name != "Outer Transaction College" {
+				t.Errorf("school added outside the nested transaction = %+v, %v, want it unchanged", school, err)
+			}
+			if _, err := store.GetSchool(ctx, dropped.ID); err == nil {
+				t.Errorf("school added in a rolled back nested transaction exists")
+			}
+			after, err := store.Revision(ctx)
+			if err != nil {
+				t.Fatalf("Revision: %v", err)
+			}
+			if after.Number != before.Number+1 {
+				t.Errorf("revision went from %d to %d, want one change", before.Number, after.Number)
+			}
+		})
+	}
+}
+
+// TestInTransactionRollback checks that every kind of change, including to
+// the names that are checked for conflicts, is undone by a rollback.
+func TestInTransactionRollback(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			renamed, err := store.GetSchool(ctx, 1)
+			if err != nil {
+				t.Fatalf("GetSchool: %v", err)
+			}
+			if err := store.DeleteSchool(ctx, 2, 0); err != nil {
+				t.Fatalf("DeleteSchool: %v", err)
+			}
+			if err := store.DeleteSchool(ctx, 3, 0); err != nil {
+				t.Fatalf("DeleteSchool: %v", err)
+			}
+			before, err := store.Revision(ctx)
+			if err != nil {
+				t.Fatalf("Revision: %v", err)
+			}
+
+			errRollback := errors.New("rollback")
+			err = store.InTransaction(ctx, func(tx SchoolStore) error {
+				if _, err := tx.UpdateSchool(ctx, School{ID: 1, Name: "Renamed In Transaction College"}); err != nil {
+					return err
+				}
+				if _, err := tx.AddSchool(ctx, School{Name: renamed.Name}); err != nil {
+					return err
+				}
+				if _, err := tx.RestoreSchool(ctx, 3); err != nil {
+					return err
+				}
+				if _, err := tx.PurgeSchools(ctx, time.Now().Add(time.Second)); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if err != errRollback {
+				t.Fatalf("InTransaction returned %v, want %v", err, errRollback)
+			}
+
+			if school, err := store.GetSchool(ctx, 1); err != nil || school.Name != renamed.Name || school.Version != renamed.Version {
+				t.Errorf("GetSchool(1) = %+v, %v, want %+v", school, err, renamed)
+			}
+			for _, id := range []int{2, 3} {
+				if _, err := store.GetSchool(ctx, id); !errors.As(err, new(*GoneError)) {
+					t.Errorf("GetSchool(%d) returned %v, want a *GoneError", id, err)
+				}
+			}
+			if rev, err := store.Revision(ctx); err != nil || rev.Number != before.Number {
+				t.Errorf("Revision = %+v, %v, want %+v", rev, err, before)
+			}
+
+			var conflict *ConflictError
+			if _, err := store.AddSchool(ctx, School{Name: renamed.Name}); !errors.As(err, &conflict) || conflict.ID != 1 {
+				t.Errorf("AddSchool with the name of school 1 returned %v, want a conflict with it", err)
+			}
+			if _, err := store.AddSchool(ctx, School{Name: "Renamed In Transaction College"}); err != nil {
+				t.Errorf("AddSchool with the name from the rolled back rename: %v", err)
+			}
+		})
+	}
+}
+
+func TestVersions(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			added, err := store.AddSchool(ctx, School{Name: "Version University"})
+			if err != nil {
+				t.Fatalf("AddSchool: %v", err)
+			}
+			if added.Version != 1 {
+				t.Errorf("AddSchool version = %d, want 1", added.Version)
+			}
+
+			// Updates at the current version, or at no version, count it up
+			updated, err := store.UpdateSchool(ctx, School{ID: added.ID, Name: "Version University", City: "Auburn", Version: 1})
+			if err != nil {
+				t.Fatalf("UpdateSchool: %v", err)
+			}
+			if updated.Version != 2 {
+				t.Errorf("UpdateSchool version = %d, want 2", updated.Version)
+			}
+			if updated, err = store.UpdateSchool(ctx, School{ID: added.ID, Name: "Version University"}); err != nil || updated.Version != 3 {
+				t.Errorf("UpdateSchool without a version = %+v, %v, want version 3", updated, err)
+			}
+
+			// Changes at an earlier version are rejected
+			var mismatch *VersionMismatchError
+			_, err = store.UpdateSchool(ctx, School{ID: added.ID, Name: "Stale University", Version: 2})
+			if !errors.As(err, &mismatch) || mismatch.Version != 2 {
+				t.Errorf("UpdateSchool at version 2 error = %v, want a *VersionMismatchError", err)
+			}
+			if err := store.DeleteSchool(ctx, added.ID, 2); !errors.As(err, &mismatch) {
+				t.Errorf("DeleteSchool at version 2 error = %v, want a *VersionMismatchError", err)
+			}
+			if got, err := store.GetSchool(ctx, added.ID); err != nil || got.Name != "Version University" || got.Version != 3 {
+				t.Errorf("GetSchool = %+v, %v, want the school unchanged at version 3", got, err)
+			}
+
+			if err := store.DeleteSchool(ctx, added.ID, 3); err != nil {
+				t.Fatalf("DeleteSchool at version 3: %v", err)
+			}
+			restored, err := store.RestoreSchool(ctx, added.ID)
+			if err != nil || restored.Version != 5 {
+				t.Errorf("RestoreSchool = %+v, %v, want version 5", restored, err)
+			}
+		})
+	}
+}
+
+func TestRevision(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			prev, err := store.Revision(ctx)
+			if err != nil {
+				t.Fatalf("Revision: %v", err)
+			}
+			if prev.Epoch == "" || prev.ModifiedAt.IsZero() {
+				t.Errorf("revision %+v has no epoch or modification time", prev)
+			}
+
+			// check fails the test unless the revision changed as expected
+			// since the last check.
+			check := func(op string, changed bool) {
+				t.Helper()
+				rev, err := store.Revision(ctx)
+				if err != nil {
+					t.Fatalf("Revision: %v", err)
+				}
+				if rev.Epoch != prev.Epoch {
+					t.Errorf("epoch changed from %q to %q after %s", prev.Epoch, rev.Epoch, op)
+				}
+				if (rev.Number != prev.Number) != changed {
+					t.Errorf("revision went from %d to %d after %s", prev.Number, rev.Number, op)
+				}
+				if rev.ModifiedAt.Before(prev.ModifiedAt) || rev.ModifiedAt.Equal(prev.ModifiedAt) == changed {
+					t.Errorf("modification time went from %v to %v after %s", prev.ModifiedAt, rev.ModifiedAt, op)
+				}
+				prev = rev
+			}
+
+			added, err := store.AddSchool(ctx, School{Name: "Revision College"})
+			if err != nil {
+				t.Fatalf("AddSchool: %v", err)
+			}
+			check("AddSchool", true)
+			if !added.UpdatedAt.Equal(prev.ModifiedAt) {
+				t.Errorf("added school was updated at %v, want %v", added.UpdatedAt, prev.ModifiedAt)
+			}
+
+			store.UpdateSchool(ctx, School{ID: added.ID, Name: "Revision University"})
+			check("UpdateSchool", true)
+
+			store.UpdateSchool(ctx, School{ID: -1, Name: "Missing"})
+			check("UpdateSchool of a missing school", false)
+
+			store.RestoreSchool(ctx, added.ID)
+			check("RestoreSchool of an active school", false)
+
+			store.InTransaction(ctx, func(tx SchoolStore) error {
+				_, err := tx.RestoreSchool(ctx, added.ID)
+				return err
+			})
+			check("RestoreSchool of an active school in a transaction", false)
+
+			store.DeleteSchool(ctx, added.ID, 0)
+			check("DeleteSchool", true)
+
+			store.RestoreSchool(ctx, added.ID)
+			check("RestoreSchool", true)
+
+			store.PurgeSchools(ctx, time.Now())
+			check("PurgeSchools with nothing to purge", false)
+
+			store.DeleteSchool(ctx, added.ID, 0)
+			store.PurgeSchools(ctx, time.Now().Add(time.Minute))
+			prev.Number++
+			check("PurgeSchools", true)
+		})
+	}
+}
+
+func TestIdempotentRequests(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			keys := store.(IdempotencyStore)
+			expiresAt := time.Now().Add(time.Hour)
+
+			record, started, err := keys.BeginRequest(ctx, "key-1", "first", expiresAt)
+			if err != nil || !started {
+				t.Fatalf("BeginRequest() = %+v, %v, %v, want a started request", record, started, err)
+			}
+
+			// A repeat sees the first request in progress
+			record, started, err = keys.BeginRequest(ctx, "key-1", "second", expiresAt)
+			if err != nil || started || record.Fingerprint != "first" || record.Response != nil {
+				t.Fatalf("BeginRequest() of a repeat = %+v, %v, %v, want the first request in progress", record, started, err)
+			}
+
+			response := StoredResponse{
+				Status: 201,
+				Header: map[string][]string{"Location": {"/schools/12"}},
+				Body:   []byte(`{"id":12}`),
+			}
+			if err := keys.CompleteRequest(ctx, "key-1", response, expiresAt); err != nil {
+				t.Fatalf("CompleteRequest: %v", err)
+			}
+			record, started, err = keys.BeginRequest(ctx, "key-1", "first", expiresAt)
+			if err != nil || started || record.Response == nil {
+				t.Fatalf("BeginRequest() of a completed request = %+v, %v, %v, want its response", record, started, err)
+			}
+			if got := record.Response; got.Status != 201 || got.Header["Location"][0] != "/schools/12" || string(got.Body) != `{"id":12}` {
+				t.Errorf("stored response = %+v, want %+v", got, response)
+			}
+
+			// Abandoned and expired requests can be started again
+			keys.BeginRequest(ctx, "key-2", "first", expiresAt)
+			if err := keys.AbandonRequest(ctx, "key-2"); err != nil {
+				t.Fatalf("AbandonRequest: %v", err)
+			}
+			if _, started, err := keys.BeginRequest(ctx, "key-2", "second", expiresAt); err != nil || !started {
+				t.Errorf("BeginRequest() of an abandoned request = %v, %v, want started", started, err)
+			}
+			keys.BeginRequest(ctx, "key-3", "first", time.Now().Add(-time.Second))
+			if _, started, err := keys.BeginRequest(ctx, "key-3", "second", expiresAt); err != nil || !started {
+				t.Errorf("BeginRequest() of an expired request = %v, %v, want started", started, err)
+			}
+
+			// Completing a request keeps its response past the lease it
+			// was started with
+			keys.BeginRequest(ctx, "key-4", "first", time.Now().Add(-time.Second))
+			keys.CompleteRequest(ctx, "key-4", response, expiresAt)
+			if record, started, err := keys.BeginRequest(ctx, "key-4", "first", expiresAt); err != nil || started || record.Response == nil {
+				t.Errorf("BeginRequest() of a request completed after its lease = %+v, %v, %v, want its response", record, started, err)
+			}
+
+			keys.BeginRequest(ctx, "key-5", "first", time.Now().Add(-time.Second))
+			purged, err := keys.PurgeRequests(ctx, time.Now())
+			if err != nil || purged != 1 {
+				t.Errorf("PurgeRequests() = %d, %v, want 1", purged, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	same := [][]string{
+		{"St. Mary's College", "st marys  college", "ST MARYS COLLEGE"},
+		{"Wilkes-Barre Area", "Wilkes Barre Area"},
+		{"Université Laval", "UNIVERSITÉ LAVAL"},
+	}
+	for _, names := range same {
+		for _, name := range names[1:] {
+			if NormalizeName(name) != NormalizeName(names[0]) {
+				t.Errorf("NormalizeName(%q) = %q, want %q", name, NormalizeName(name), NormalizeName(names[0]))
+			}
+		}
+	}
+	if NormalizeName("Université Laval") == NormalizeName("Universite Laval") {
+		t.Errorf("accented and unaccented names normalize the same")
+	}
+}
+
+func TestNameConflicts(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			added, err := store.AddSchool(ctx, School{Name: "Conflict Test College"})
+			if err != nil {
+				t.Fatalf("AddSchool: %v", err)
+			}
+			other, err := store.AddSchool(ctx, School{Name: "Other Conflict Test College"})
+			if err != nil {
+				t.Fatalf("AddSchool: %v", err)
+			}
+
+			// checkConflict fails the test unless err is a conflict with
+			// the school added above, which is named name.
+			checkConflict := func(op string, err error, name string, deleted bool) {
+				t.Helper()
+				var conflict *ConflictError
+				if !errors.As(err, &conflict) {
+					t.Fatalf("%s returned %v, want a *ConflictError", op, err)
+				}
+				want := ConflictError{ID: added.ID, Name: name, Deleted: deleted}
+				if *conflict != want {
+					t.Errorf("%s returned %+v, want %+v", op, *conflict, want)
+				}
+			}
+
+			_, err = store.AddSchool(ctx, School{Name: " conflict-test  COLLEGE "})
+			checkConflict("AddSchool", err, "Conflict Test College", false)
+
+			_, err = store.UpdateSchool(ctx, School{ID: other.ID, Name: "Conflict Test College."})
+			checkConflict("UpdateSchool", err, "Conflict Test College", false)
+
+			// A school can always keep its own name, even if it is shared
+			// with another school from before names had to be unique.
+			if _, err := store.UpdateSchool(ctx, School{ID: added.ID, Name: "Conflict test college", City: "Auburn"}); err != nil {
+				t.Errorf("UpdateSchool keeping the name: %v", err)
+			}
+			seeded, err := store.GetSchools(ctx, ListOptions{Limit: 2, Filter: NameFilter{Exact: "Glendale Community College"}})
+			if err != nil || len(seeded.Schools) != 2 {
+				t.Fatalf("GetSchools returned %v, %v, want the two seeded schools named Glendale Community College", seeded.Schools, err)
+			}
+			if _, err := store.UpdateSchool(ctx, School{ID: seeded.Schools[0].ID, Name: "Glendale Community College", State: "AZ"}); err != nil {
+				t.Errorf("UpdateSchool of a seeded duplicate: %v", err)
+			}
+
+			// Deleted schools keep their names until they are purged
+			if err := store.DeleteSchool(ctx, added.ID, 0); err != nil {
+				t.Fatalf("DeleteSchool: %v", err)
+			}
+			_, err = store.AddSchool(ctx, School{Name: "Conflict Test College"})
+			checkConflict("AddSchool", err, "Conflict test college", true)
+			if _, err := store.PurgeSchools(ctx, time.Now().Add(time.Minute)); err != nil {
+				t.Fatalf("PurgeSchools: %v", err)
+			}
+			if _, err := store.AddSchool(ctx, School{Name: "Conflict Test College"}); err != nil {
+				t.Errorf("AddSchool after purging: %v", err)
+			}
+		})
+	}
+}
+
+// TestConcurrentConflictingAdds adds schools with the same name from many
+// goroutines at once and checks that exactly one of them is added.
+func TestConcurrentConflictingAdds(t *testing.T) {
+	const workers = 8
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			errs := make(chan error, workers)
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := store.AddSchool(ctx, School{Name: "Race Condition College"})
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			added := 0
+			for err := range errs {
+				var conflict *ConflictError
+				switch {
+				case err == nil:
+					added++
+				case !errors.As(err, &conflict):
+					t.Errorf("AddSchool: %v", err)
+				}
+			}
+			if added != 1 {
+				t.Errorf("added %d schools with the same name, want 1", added)
+			}
+		})
+	}
+}
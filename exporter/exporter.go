@@ -0,0 +1,190 @@
+// Package exporter writes every active school in a store to a file. Schools
+// are read and written in batches so that the whole collection is never held
+// in memory at once, which lets exports be streamed to slow clients.
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/resources"
+)
+
+// Formats that schools can be exported in
+const (
+	// FormatCSV is a CSV file with a header row naming the columns, which
+	// have the same names as the fields of a school in the API.
+	FormatCSV = "csv"
+
+	// FormatJSONL is a JSON Lines file with one school object per line.
+	FormatJSONL = "jsonl"
+
+	// FormatJSON is a JSON array of school objects.
+	FormatJSON = "json"
+)
+
+// batchSize is the number of schools read from the store at a time.
+const batchSize = 500
+
+// encoder writes schools in one of the export formats.
+type encoder interface {
+	begin() error
+	encode(school *resources.School) error
+	end() error
+
+	// flush writes any data buffered by the encoder.
+	flush() error
+}
+
+// Export writes every active school in store to w in the specified format,
+// ordered by id. Each batch of schools is read from a consistent snapshot of
+// the store, but a school changed while the export runs may be written with
+// either its old or its new fields. No school is skipped or written twice.
+//
+// w is flushed after every batch if it is an http.Flusher.
+func Export(ctx context.Context, store db.SchoolStore, w io.Writer, format string) error {
+	buf := bufio.NewWriter(w)
+	var enc encoder
+	switch format {
+	case FormatCSV:
+		enc = &csvEncoder{w: csv.NewWriter(buf)}
+	case FormatJSONL:
+		enc = &jsonlEncoder{enc: json.NewEncoder(buf)}
+	case FormatJSON:
+		enc = &jsonEncoder{w: buf}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	flush := func() error {
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	if err := enc.begin(); err != nil {
+		return err
+	}
+
+	opts := db.ListOptions{Limit: batchSize}
+	for {
+		page, err := store.GetSchools(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for i := range page.Schools {
+			school := resources.NewSchool(&page.Schools[i])
+			if err := enc.encode(&school); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		if len(page.Schools) < batchSize {
+			break
+		}
+		last := page.Schools[len(page.Schools)-1]
+		opts.After = &db.Position{ID: last.ID}
+	}
+
+	if err := enc.end(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// csvEncoder writes schools as CSV rows after a header row.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) begin() error {
+	return e.w.Write(resources.CSVHeader())
+}
+
+func (e *csvEncoder) encode(school *resources.School) error {
+	return e.w.Write(school.CSVRecord())
+}
+
+func (e *csvEncoder) end() error {
+	return nil
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonlEncoder writes schools as JSON objects, one per line.
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) begin() error {
+	return nil
+}
+
+func (e *jsonlEncoder) encode(school *resources.School) error {
+	return e.enc.Encode(school)
+}
+
+func (e *jsonlEncoder) end() error {
+	return nil
+}
+
+func (e *jsonlEncoder) flush() error {
+	return nil
+}
+
+// jsonEncoder writes schools as the elements of a JSON array, one per line.
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonEncoder) encode(school *resources.School) error {
+	b, err := json.Marshal(school)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "\n"
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonEncoder) end() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+func (e *jsonEncoder) flush() error {
+	return nil
+}
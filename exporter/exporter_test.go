@@ -0,0 +1,125 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/importer"
+	"github.com/clinstid/schools_api/resources"
+)
+
+// newTestStore returns a seeded store with a deleted school, which exports
+// must leave out, and a school with every field set.
+func newTestStore(t *testing.T) (db.SchoolStore, int) {
+	store := db.NewMemoryStore()
+	ctx := context.Background()
+	if err := store.DeleteSchool(ctx, 1, 0); err != nil {
+		t.Fatalf("DeleteSchool: %v", err)
+	}
+	_, err := store.AddSchool(ctx, db.School{
+		Name:       "Export, \"Quoted\" College",
+		City:       "Auburn",
+		Country:    "US",
+		Type:       db.TypePublic,
+		Website:    "https://example.edu",
+		ExternalID: "900003",
+	})
+	if err != nil {
+		t.Fatalf("AddSchool: %v", err)
+	}
+
+	all, err := store.GetSchools(ctx, db.ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetSchools: %v", err)
+	}
+	return store, all.Total
+}
+
+func TestExportJSON(t *testing.T) {
+	store, total := newTestStore(t)
+	var out bytes.Buffer
+	if err := Export(context.Background(), store, &out, FormatJSON); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var schools []resources.School
+	if err := json.Unmarshal(out.Bytes(), &schools); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if len(schools) != total {
+		t.Fatalf("exported %d schools, want %d", len(schools), total)
+	}
+	for i := 1; i < len(schools); i++ {
+		if schools[i].ID <= schools[i-1].ID {
+			t.Fatalf("school %d exported after school %d", schools[i].ID, schools[i-1].ID)
+		}
+		if schools[i].ID == 1 {
+			t.Errorf("deleted school was exported")
+		}
+	}
+	if last := schools[len(schools)-1]; last.City != "Auburn" || last.ExternalID != "900003" {
+		t.Errorf("last school exported as %+v", last)
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	store, total := newTestStore(t)
+	var out bytes.Buffer
+	if err := Export(context.Background(), store, &out, FormatJSONL); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var school resources.School
+		if err := json.Unmarshal(scanner.Bytes(), &school); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v", lines+1, err)
+		}
+		lines++
+	}
+	if lines != total {
+		t.Errorf("exported %d lines, want %d", lines, total)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	store, total := newTestStore(t)
+	var out bytes.Buffer
+	if err := Export(context.Background(), store, &out, FormatCSV); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(out.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if strings.Join(records[0], ",") != strings.Join(resources.CSVHeader(), ",") {
+		t.Errorf("header row is %v, want %v", records[0], resources.CSVHeader())
+	}
+	if len(records)-1 != total {
+		t.Errorf("exported %d rows, want %d", len(records)-1, total)
+	}
+	if last := records[len(records)-1]; last[1] != "Export, \"Quoted\" College" {
+		t.Errorf("last row is %v", last)
+	}
+
+	// An exported school can be imported again. The seeded schools cannot,
+	// since some of them share a name.
+	var reimport bytes.Buffer
+	w := csv.NewWriter(&reimport)
+	w.WriteAll([][]string{records[0], records[len(records)-1]})
+	result, err := importer.Import(context.Background(), db.NewMemoryStore(), &reimport, importer.FormatCSV, importer.Options{})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.Created != 1 {
+		t.Errorf("imported %d schools, want 1", result.Created)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/resources"
+	"github.com/clinstid/schools_api/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// errBatchFailed rolls back the transaction of an atomic batch when one of
+// its operations fails.
+var errBatchFailed = errors.New("batch operation failed")
+
+// BatchSchools applies a batch of create, update and delete operations to
+// schools:
+//
+// ```json
+// {
+//   "mode": "atomic",
+//   "operations": [
+//     { "op": "create", "school": { "name": "New School" } },
+//     { "op": "update", "id": 12, "if_match": "\"3\"", "school": { "name": "Auburn University" } },
+//     { "op": "delete", "id": 13 }
+//   ]
+// }
+// ```
+//
+// In the default `atomic` mode the operations are applied in one transaction,
+// so either all of them are applied or, if any of them fails, none are and a
+// 422 response is returned. In `partial` mode each operation is applied on its
+// own and the response is always a 200. Either way the response has a result
+// for each operation, in order, with the status, ETag and body of the response
+// to the same change made with AddSchool, UpdateSchool or DeleteSchool. The
+// operations that were not applied because another failed have the status
+// 424, and the result of the one that failed does not refer to the schools
+// created or the versions made by the operations before it, since they were
+// rolled back too.
+func (h *Handler) BatchSchools(c *gin.Context) {
+	var batch resources.Batch
+	if err := c.ShouldBind(&batch); err != nil {
+		c.JSON(http.StatusBadRequest, buildBindErrorResponse(err))
+		return
+	}
+	epoch, ok := h.epoch(c)
+	if !ok {
+		return
+	}
+
+	results := make([]resources.BatchResult, len(batch.Operations))
+	if batch.Mode == resources.BatchPartial {
+		for i, op := range batch.Operations {
+			results[i] = h.applyOperation(c.Request, h.store, epoch, op)
+		}
+		c.JSON(http.StatusOK, resources.BatchResults{Results: results})
+		return
+	}
+
+	failed := -1
+	err := h.store.InTransaction(c.Request.Context(), func(tx db.SchoolStore) error {
+		for i, op := range batch.Operations {
+			results[i] = h.applyOperation(c.Request, tx, epoch, op)
+			if results[i].Status >= http.StatusBadRequest {
+				failed = i
+				results[i] = forgetRolledBack(results[:i], results[i])
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+	switch {
+	case failed >= 0 && results[failed].Status >= http.StatusInternalServerError:
+		c.JSON(http.StatusInternalServerError, buildErrorResponse(internalErrMsg))
+	case failed >= 0:
+		// Nothing was applied, so every other operation is reported as
+		// failing because of the one that failed.
+		notApplied := buildErrorResponse(fmt.Sprintf(batchNotAppliedErrMsg, failed))
+		for i, op := range batch.Operations {
+			if i != failed {
+				results[i] = resources.BatchResult{Status: http.StatusFailedDependency, ID: op.ID, Error: notApplied}
+			}
+		}
+		c.JSON(http.StatusUnprocessableEntity, resources.BatchResults{
+			Message: fmt.Sprintf(batchFailedErrMsg, failed),
+			Results: results,
+		})
+	case err != nil:
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+	default:
+		c.JSON(http.StatusOK, resources.BatchResults{Results: results})
+	}
+}
+
+// forgetRolledBack returns the result of the operation of an atomic batch that
+// failed after the operations with the results applied, without the ids,
+// links and ETags of the schools they created or changed, which were rolled
+// back along with it. A conflict with a school created by one of them is
+// described by the operation that created it instead.
+func forgetRolledBack(applied []resources.BatchResult, failed resources.BatchResult) resources.BatchResult {
+	for i, result := range applied {
+		if result.ID == nil {
+			continue
+		}
+		if conflict, ok := failed.Error.(resources.Conflict); ok && result.Status == http.StatusCreated && conflict.ID == *result.ID {
+			failed.Error = buildErrorResponse(fmt.Sprintf(batchConflictErrMsg, i, result.School.Name))
+		}
+		if failed.ID != nil && *failed.ID == *result.ID {
+			failed.ETag = ""
+		}
+	}
+	return failed
+}
+
+// applyOperation applies one operation of a batch to store, whose epoch is
+// epoch, and returns its result. The operation is checked as the request for
+// the same change made on its own would be, including against
+// Options.RequireIfMatch.
+func (h *Handler) applyOperation(r *http.Request, store db.SchoolStore, epoch string, op resources.BatchOperation) resources.BatchResult {
+	ctx := r.Context()
+	fail := func(status int, body interface{}) resources.BatchResult {
+		return resources.BatchResult{Status: status, ID: op.ID, Error: body}
+	}
+	failWith := func(err error) resources.BatchResult {
+		result := fail(buildIfMatchErrorResponse(r, err))
+		if failed, ok := err.(*ifMatchFailedError); ok {
+			result.ETag = failed.etag
+		}
+		return result
+	}
+	succeed := func(status int, school *db.School) resources.BatchResult {
+		resp := resources.NewSchool(school)
+		return resources.BatchResult{Status: status, ID: &resp.ID, ETag: schoolETag(epoch, school), School: &resp}
+	}
+
+	if op.ID == nil && (op.Op == resources.BatchUpdate || op.Op == resources.BatchDelete) {
+		return fail(http.StatusBadRequest, buildErrorResponse(batchIDRequiredErrMsg))
+	}
+	if op.Op == resources.BatchCreate || op.Op == resources.BatchUpdate {
+		if op.School == nil {
+			return fail(http.StatusBadRequest, buildErrorResponse(batchSchoolRequiredErrMsg))
+		}
+		if err := validation.Struct(op.School); err != nil {
+			return fail(http.StatusBadRequest, buildBindErrorResponse(err))
+		}
+	}
+
+	switch op.Op {
+	case resources.BatchCreate:
+		added, err := store.AddSchool(ctx, op.School.StoreSchool(0))
+		if err != nil {
+			return failWith(err)
+		}
+		return succeed(http.StatusCreated, added)
+
+	case resources.BatchUpdate:
+		version, err := h.checkIfMatch(ctx, store, *op.ID, op.IfMatch)
+		if err != nil {
+			return failWith(err)
+		}
+		record := op.School.StoreSchool(*op.ID)
+		record.Version = version
+		updated, err := store.UpdateSchool(ctx, record)
+		if err != nil {
+			return failWith(err)
+		}
+		return succeed(http.StatusOK, updated)
+
+	case resources.BatchDelete:
+		version, err := h.checkIfMatch(ctx, store, *op.ID, op.IfMatch)
+		if err != nil {
+			return failWith(err)
+		}
+		if err := store.DeleteSchool(ctx, *op.ID, version); err != nil {
+			return failWith(err)
+		}
+		return resources.BatchResult{Status: http.StatusNoContent, ID: op.ID}
+
+	default:
+		return fail(http.StatusBadRequest, buildErrorResponse(batchOpInvalidErrMsg))
+	}
+}
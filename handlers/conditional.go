@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/clinstid/schools_api/db"
+	"github.com/gin-gonic/gin"
+)
+
+// representationETag returns the entity tag of the mediaType representation
+// of a resource whose JSON representation has the entity tag etag. Each
+// representation needs its own strong entity tag, so caches that store
+// several of them can tell which one a 304 response refers to.
+func representationETag(etag, mediaType string) string {
+	if mediaType == mediaJSON {
+		return etag
+	}
+	subtype := mediaType[strings.Index(mediaType, "/")+1:]
+	return fmt.Sprintf(`%s-%s"`, strings.TrimSuffix(etag, `"`), subtype)
+}
+
+// schoolMatches reports whether the If-Match header value matches the entity
+// tag of any representation of a school whose JSON representation has the
+// entity tag etag.
+func schoolMatches(ifMatch, etag string) bool {
+	for _, mediaType := range schoolMediaTypes {
+		if ifMatches(ifMatch, representationETag(etag, mediaType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectionETag returns the entity tag of the mediaType representation of
+// the schools listed by r at revision rev. It changes whenever any school
+// changes, as well as with the query parameters of r.
+func collectionETag(r *http.Request, rev db.Revision, mediaType string) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s %s %s", mediaType, r.Host, r.URL.RequestURI())
+	return fmt.Sprintf(`"%s-%d-%x"`, rev.Epoch, rev.Number, h.Sum64())
+}
+
+// setValidators sets the ETag, Last-Modified and Cache-Control headers of a
+// response with a representation with the entity tag etag that was last
+// modified at modified. They are only set once the representation has been
+// read, so that error responses don't carry them.
+func setValidators(c *gin.Context, etag string, modified time.Time) {
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache")
+}
+
+// notModified reports whether a GET request for a representation with the
+// entity tag etag that was last modified at modified can be answered with a
+// 304 response, and if so sends one with the validators of the
+// representation. That is the case if the request has an If-None-Match
+// header that matches etag, or has no If-None-Match header and an
+// If-Modified-Since header no earlier than modified.
+func notModified(c *gin.Context, etag string, modified time.Time) bool {
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		if !etagMatches(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		// HTTP dates only have whole seconds, so the modification time
+		// is truncated before comparing.
+		since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		if err != nil || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+	setValidators(c, etag, modified)
+	c.Status(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/clinstid/schools_api/cursor"
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/search"
+)
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modified := time.Date(2020, 5, 1, 12, 30, 15, 500000000, time.UTC)
+	etag := `"3"`
+
+	tests := []struct {
+		ifNoneMatch, ifModifiedSince string
+		want                         bool
+	}{
+		{"", "", false},
+		{`"3"`, "", true},
+		{`W/"3"`, "", true},
+		{`"2", "3"`, "", true},
+		{"*", "", true},
+		{`"2"`, "", false},
+		// If-Modified-Since is ignored when If-None-Match is sent
+		{`"2"`, "Fri, 01 May 2020 12:30:15 GMT", false},
+		{"", "Fri, 01 May 2020 12:30:15 GMT", true},
+		{"", "Sat, 02 May 2020 00:00:00 GMT", true},
+		{"", "Fri, 01 May 2020 12:30:14 GMT", false},
+		{"", "yesterday", false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/schools/1", nil)
+		if tt.ifNoneMatch != "" {
+			c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		if tt.ifModifiedSince != "" {
+			c.Request.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+		}
+
+		if got := notModified(c, etag, modified); got != tt.want {
+			t.Errorf("notModified(If-None-Match %q, If-Modified-Since %q) = %v, want %v", tt.ifNoneMatch, tt.ifModifiedSince, got, tt.want)
+		}
+		c.Writer.WriteHeaderNow()
+		if tt.want && w.Code != http.StatusNotModified {
+			t.Errorf("notModified(If-None-Match %q, If-Modified-Since %q) status = %d, want %d", tt.ifNoneMatch, tt.ifModifiedSince, w.Code, http.StatusNotModified)
+		}
+
+		// The validators are only sent with a 304 response, and are
+		// otherwise left to the handler
+		wantETag, wantModified := "", ""
+		if tt.want {
+			wantETag, wantModified = etag, "Fri, 01 May 2020 12:30:15 GMT"
+		}
+		if got := w.Header().Get("ETag"); got != wantETag {
+			t.Errorf("ETag = %q, want %q", got, wantETag)
+		}
+		if got := w.Header().Get("Last-Modified"); got != wantModified {
+			t.Errorf("Last-Modified = %q, want %q", got, wantModified)
+		}
+	}
+}
+
+// brokenStore is a SchoolStore whose schools cannot be read.
+type brokenStore struct {
+	db.SchoolStore
+}
+
+func (s brokenStore) GetSchools(ctx context.Context, opts db.ListOptions) (db.SchoolsResult, error) {
+	return db.SchoolsResult{}, errors.New("connection lost")
+}
+
+func TestErrorsHaveNoValidators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := New(brokenStore{db.NewMemoryStore()}, search.NewIndex(), cursor.NewCodec([]byte("secret")), Options{})
+	r := gin.New()
+	r.GET("/schools", h.ListSchools)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/schools", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	for _, header := range []string{"ETag", "Last-Modified", "Cache-Control"} {
+		if got := w.Header().Get(header); got != "" {
+			t.Errorf("%s = %q, want none", header, got)
+		}
+	}
+}
+
+func TestRepresentationETag(t *testing.T) {
+	school := &db.School{ID: 1, Version: 3}
+	tests := []struct {
+		mediaType, want string
+	}{
+		{mediaJSON, `"e1-3"`},
+		{mediaCSV, `"e1-3-csv"`},
+		{mediaNDJSON, `"e1-3-x-ndjson"`},
+	}
+	for _, tt := range tests {
+		etag := representationETag(schoolETag("e1", school), tt.mediaType)
+		if etag != tt.want {
+			t.Errorf("representationETag(%s) = %s, want %s", tt.mediaType, etag, tt.want)
+		}
+		// Any representation's ETag can be sent in If-Match
+		if !schoolMatches(etag, schoolETag("e1", school)) {
+			t.Errorf("schoolMatches(%s) = false, want true", etag)
+		}
+	}
+	for _, etag := range []string{`"e1-2-csv"`, `"e2-3"`, `"3"`} {
+		if schoolMatches(etag, schoolETag("e1", school)) {
+			t.Errorf("schoolMatches(%s) = true, want false", etag)
+		}
+	}
+}
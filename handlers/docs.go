@@ -0,0 +1,729 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/exporter"
+	"github.com/clinstid/schools_api/openapi"
+	"github.com/clinstid/schools_api/resources"
+)
+
+// API describes the operations of the handlers and the resources they use
+// for the OpenAPI specification, which is generated from the routes of the
+// service. The limits, defaults and allowed values of the parameters are the
+// ones the handlers use, so the specification cannot disagree with them.
+var API = openapi.API{
+	Info: &openapi3.Info{
+		Title:       "Schools API",
+		Description: "This API manages a list of colleges in the United States.",
+		License:     &openapi3.License{Name: "MIT"},
+		Version:     "1.0.0",
+	},
+	Operations: map[string]openapi.Operation{
+		"ListSchools":         listSchoolsDoc,
+		"AddSchool":           addSchoolDoc,
+		"ImportSchools":       importSchoolsDoc,
+		"BatchSchools":        batchSchoolsDoc,
+		"ExportSchools":       exportSchoolsDoc,
+		"SearchSchools":       searchSchoolsDoc,
+		"AutocompleteSchools": autocompleteSchoolsDoc,
+		"GetSchool":           getSchoolDoc,
+		"UpdateSchool":        updateSchoolDoc,
+		"PatchSchool":         patchSchoolDoc,
+		"DeleteSchool":        deleteSchoolDoc,
+		"RestoreSchool":       restoreSchoolDoc,
+	},
+	Schemas: map[string]openapi.Schema{
+		"School": {
+			Description: "A school object. Every field other than `name` is optional and is " +
+				"left out of responses when it is not set. Updating a school replaces " +
+				"all of its fields. Leading and trailing whitespace is trimmed from " +
+				"every field before it is validated, so a `name` of only whitespace is " +
+				"rejected as missing, and lengths are counted in characters after " +
+				"trimming.",
+			Fields: map[string]string{
+				"id": "The id of the school. Ids are assigned when a school is added and " +
+					"are never changed or reused, so they can be cached indefinitely.",
+				"name":        "The name of the school. It must not contain control characters.",
+				"city":        "The city the school is in. It must not contain control characters.",
+				"state":       "The state, province or region the school is in. It must not contain control characters.",
+				"postal_code": "The postal code of the school's address. It must not contain control characters.",
+				"country":     "The ISO 3166-1 alpha-2 code of the country the school is in.",
+				"type":        "Who runs the institution.",
+				"level":       "The length of the longest program the school offers.",
+				"website":     "The http or https URL of the school's website.",
+				"external_id": "The identifier of the school in an external dataset, such as its " +
+					"IPEDS Unit ID. It must only contain printable ASCII characters.",
+				"deleted_at": "When the school was deleted. Only present on deleted schools " +
+					"listed with `deleted=true`.",
+			},
+			ReadOnly: []string{"id", "deleted_at"},
+		},
+		"Schools": {
+			Description: "A page of schools. In XML the root element is `schools` and each " +
+				"school is a `school` element.",
+		},
+		"Meta": {
+			Fields: map[string]string{
+				"total": "The total number of schools matching the filters.",
+				"next_cursor": "The cursor for the next page when paginating with `cursor`. " +
+					"Absent on the last page.",
+			},
+		},
+		"Links": {
+			Fields: map[string]string{
+				"first": "A URL that links to the first page of results.",
+				"last":  "A URL that links to the last page of results. Not returned when paginating with `cursor`.",
+				"next":  "A URL that links to the next page of results.",
+				"prev":  "A URL that links to the previous page of results. Not returned when paginating with `cursor`.",
+			},
+		},
+		"SearchResult": {
+			Description: "A school matched by a search.",
+			Fields: map[string]string{
+				"id":    "The id of the school.",
+				"name":  "The name of the school.",
+				"score": "How relevant the school is to the query, between 0 and 1.",
+			},
+		},
+		"ImportResult": {
+			Fields: map[string]string{
+				"created": "The number of schools added.",
+				"updated": "The number of existing schools updated.",
+			},
+		},
+		"RowError": {
+			Fields: map[string]string{
+				"line":    "The line of the file the row starts on.",
+				"field":   "The invalid field, if the problem is with a single field.",
+				"message": "What is wrong with the row.",
+			},
+		},
+		"Error": {
+			Fields: map[string]string{
+				"message": "A human readable message describing the error.",
+			},
+		},
+		"FieldError": {
+			Fields: map[string]string{
+				"field":   "The name of the invalid field or parameter.",
+				"message": "What is wrong with the field or parameter.",
+			},
+		},
+		"FieldErrors": {
+			Fields: map[string]string{
+				"message": "A human readable message describing the first error.",
+			},
+		},
+		"Batch": {
+			Description: "A batch of changes to schools.",
+			Fields: map[string]string{
+				"mode":       "`atomic` (the default) to apply every operation or none, or `partial` to apply each operation on its own.",
+				"operations": "The operations to apply, in order.",
+			},
+		},
+		"BatchOperation": {
+			Description: "A change to a school. `id` is required to update or delete a school " +
+				"and `school` to create or update one.",
+			Fields: map[string]string{
+				"op":       "The kind of change.",
+				"id":       "The id of the school to update or delete.",
+				"if_match": "The ETag of the school to update or delete, as the If-Match header of the same change made on its own.",
+			},
+		},
+		"BatchResult": {
+			Description: "The outcome of an operation, as the response to the same change made on its own.",
+			Fields: map[string]string{
+				"status": "The status of the response. 424 for operations of an `atomic` batch that were not applied because another failed.",
+				"id":     "The id of the school.",
+				"etag":   "The ETag header of the response.",
+				"error":  "The body of the response when the operation failed.",
+			},
+		},
+		"BatchResults": {
+			Fields: map[string]string{
+				"message": "Describes why none of the operations were applied, when one of an `atomic` batch failed.",
+				"results": "The result of each operation, in the same order as the operations.",
+			},
+		},
+		"Conflict": {
+			Fields: map[string]string{
+				"message": "A human readable message describing the error.",
+				"id":      "The id of the school that already has the name.",
+				"link":    "A URL that links to the school that already has the name.",
+			},
+		},
+	},
+}
+
+// jsonContent describes a JSON body encoded from or decoded to v.
+func jsonContent(v interface{}) map[string]interface{} {
+	return map[string]interface{}{gin.MIMEJSON: v}
+}
+
+// schoolsContent describes the media types GetSchool and ListSchools can
+// respond with. CSV and NDJSON are not encoded from v.
+func schoolsContent(v interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		mediaJSON:   v,
+		mediaXML:    v,
+		mediaYAML:   v,
+		mediaCSV:    openapi3.NewStringSchema(),
+		mediaNDJSON: openapi3.NewStringSchema(),
+	}
+}
+
+// Parameters shared by several operations
+var (
+	schoolIDParam = openapi3.NewPathParameter(schoolIDField).
+			WithDescription("The school's identifier.").
+			WithSchema(openapi3.NewIntegerSchema())
+
+	limitParam = openapi3.NewQueryParameter(limitField).
+			WithDescription("Maximum resources to return").
+			WithSchema(openapi3.NewIntegerSchema().WithMin(minLimit).WithMax(maxLimit).WithDefault(limitDefault))
+
+	ifMatchParam = openapi3.NewHeaderParameter("If-Match").
+			WithDescription("The ETag of the school as it was last read. The school is only changed if it has not been changed since; `*` changes any version. Required when the service is configured with `SCHOOLS_REQUIRE_IF_MATCH`.").
+			WithSchema(openapi3.NewStringSchema())
+
+	idempotencyKeyParam = openapi3.NewHeaderParameter("Idempotency-Key").
+				WithDescription("A unique key, such as a UUID, chosen by the client for this request and sent again with every retry of it. The response is kept for 24 hours by default.").
+				WithSchema(openapi3.NewStringSchema().WithMinLength(1).WithMaxLength(maxIdempotencyKeyLength))
+
+	ifNoneMatchParam = openapi3.NewHeaderParameter("If-None-Match").
+				WithDescription("The ETag of a previous response. If it still matches, a 304 response is sent instead.").
+				WithSchema(openapi3.NewStringSchema())
+
+	ifModifiedSinceParam = openapi3.NewHeaderParameter("If-Modified-Since").
+				WithDescription("The Last-Modified time of a previous response. If nothing has changed since, a 304 response is sent instead. Ignored when If-None-Match is sent.").
+				WithSchema(openapi3.NewStringSchema())
+)
+
+// Responses shared by several operations
+var (
+	badRequestResponse = openapi.Response{
+		Status: http.StatusBadRequest,
+		Description: "A parameter is invalid. Requests are checked against this " +
+			"specification before they are handled, so `errors` lists every " +
+			"parameter that does not match it, while `message` describes the first.",
+		Content: jsonContent(resources.FieldErrors{}),
+	}
+	invalidSchoolResponse = openapi.Response{
+		Status: http.StatusBadRequest,
+		Description: "The request body is not a valid school, or a parameter is invalid. " +
+			"`message` describes the first invalid field and `errors` lists every invalid field.",
+		Content: jsonContent(resources.FieldErrors{}),
+	}
+	notFoundResponse = openapi.Response{
+		Status:      http.StatusNotFound,
+		Description: "The specified school was not found.",
+		Content:     jsonContent(resources.Error{}),
+	}
+	goneResponse = openapi.Response{
+		Status:      http.StatusGone,
+		Description: "The specified school has been deleted. It can be restored until it is purged.",
+		Content:     jsonContent(resources.Error{}),
+	}
+	notAcceptableResponse = openapi.Response{
+		Status:      http.StatusNotAcceptable,
+		Description: fmt.Sprintf("The Accept header does not allow any of %s.", strings.Join(schoolMediaTypes, ", ")),
+		Content:     jsonContent(resources.Error{}),
+	}
+	conflictResponse = openapi.Response{
+		Status: http.StatusConflict,
+		Description: "A school with the same name already exists. Names are compared " +
+			"ignoring case, whitespace and punctuation. Deleted schools keep their " +
+			"names until they are purged.",
+		Content: jsonContent(resources.Conflict{}),
+	}
+	notModifiedResponse = openapi.Response{
+		Status: http.StatusNotModified,
+		Description: "Nothing has changed since the response identified by If-None-Match " +
+			"or If-Modified-Since, which can be used again.",
+		Headers: map[string]string{"ETag": "The ETag of the response that can be used again."},
+	}
+	preconditionFailedResponse = openapi.Response{
+		Status: http.StatusPreconditionFailed,
+		Description: "The school has been changed since the version in If-Match was " +
+			"read. The ETag header has the current version when it is known.",
+		Headers: map[string]string{"ETag": etagHeaderDoc},
+		Content: jsonContent(resources.Error{}),
+	}
+	preconditionRequiredResponse = openapi.Response{
+		Status:      http.StatusPreconditionRequired,
+		Description: "The request has no If-Match header and the service requires one.",
+		Content:     jsonContent(resources.Error{}),
+	}
+	internalErrorResponse = openapi.Response{
+		Status:      http.StatusInternalServerError,
+		Description: "Internal server error.",
+		Content:     jsonContent(resources.Error{}),
+	}
+)
+
+const (
+	varyHeaderDoc = "Always `Accept`, since the media type of the response is selected by the Accept header."
+	etagHeaderDoc = "Identifies the version of the school. Send it in If-Match to only change the school if it has not been changed since."
+
+	lastModifiedHeaderDoc = "The time the school was last changed."
+
+	idempotentReplayedHeaderDoc = "`true` when the response is a replay of the response to an earlier request with the same Idempotency-Key."
+	idempotencyInProgressDoc    = "Also sent, with only a `message`, while an earlier request with the same Idempotency-Key is still in progress."
+	idempotencyKeyReusedDoc     = "The Idempotency-Key has already been used for a request with a different body."
+	cacheControlHeaderDoc       = "Always `no-cache`, so caches revalidate the response with If-None-Match or If-Modified-Since before using it again."
+)
+
+var listSchoolsDoc = openapi.Operation{
+	Summary: "List all schools",
+	Description: "Returns all schools paginated by the `limit` and `offset` parameters. " +
+		"The `q`, `prefix` and `exact` parameters filter the schools by name " +
+		"before they are paginated and `sort` orders them; the pagination links " +
+		"keep the filters and sort.",
+	Tags: []string{"schools"},
+	Parameters: []*openapi3.Parameter{
+		limitParam,
+		openapi3.NewQueryParameter(offsetField).
+			WithDescription("Offset into the collection of resources to return").
+			WithSchema(openapi3.NewIntegerSchema().WithMin(minOffSet).WithDefault(offsetDefault)),
+		openapi3.NewQueryParameter(cursorField).
+			WithDescription("Paginate with cursors instead of `offset`. Pass an empty cursor for " +
+				"the first page and `meta.next_cursor` for the following pages. Unlike " +
+				"offsets, cursors are not shifted by schools added or removed between " +
+				"requests. Cursors are opaque, signed and only valid for the `sort` " +
+				"they were issued for. Cannot be combined with `offset`.").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter(queryField).
+			WithDescription("Only return schools whose name contains this string, ignoring case. " +
+				"Applied before pagination.").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter(prefixField).
+			WithDescription("Only return schools whose name starts with this string, ignoring " +
+				"case. Applied before pagination.").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter(exactField).
+			WithDescription("Only return schools whose name is equal to this string, ignoring " +
+				"case. Applied before pagination.").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter(sortField).
+			WithDescription("Field to order the schools by, prefixed with `-` for descending " +
+				"order. Names are compared using locale-aware collation so that case " +
+				"and accents do not separate otherwise equal names, and schools with " +
+				"equal names are ordered by id. Applied before pagination.").
+			WithSchema(openapi3.NewStringSchema().WithEnum(stringsToValues(db.SortValues())...).WithDefault(sortDefault)),
+		openapi3.NewQueryParameter(deletedField).
+			WithDescription("When true, list the deleted schools that can still be restored " +
+				"instead of the active ones. Intended for administrators.").
+			WithSchema(openapi3.NewBoolSchema().WithDefault(false)),
+		ifNoneMatchParam,
+		ifModifiedSinceParam,
+	},
+	Responses: []openapi.Response{
+		{
+			Status: http.StatusOK,
+			Description: "A list of schools. CSV and NDJSON responses hold only the schools, " +
+				"with the total in the X-Total-Count header and the pagination " +
+				"links in the Link header.",
+			Headers: map[string]string{
+				"Vary":          varyHeaderDoc,
+				"X-Total-Count": "The total number of schools matching the filters, in CSV and NDJSON responses.",
+				"Link":          "The first, last, next and prev links, in CSV and NDJSON responses.",
+				"ETag":          "Identifies the page. It changes whenever any school is changed.",
+				"Last-Modified": "The time any school was last changed.",
+				"Cache-Control": cacheControlHeaderDoc,
+			},
+			Content: schoolsContent(resources.Schools{}),
+		},
+		notModifiedResponse,
+		badRequestResponse,
+		notAcceptableResponse,
+		internalErrorResponse,
+	},
+}
+
+var addSchoolDoc = openapi.Operation{
+	Summary: "Add a new school",
+	Description: "Add a new school to the list of schools. Requests with an " +
+		"Idempotency-Key header can be retried safely: repeats of the request " +
+		"are answered with the response to the first one instead of adding " +
+		"the school again.",
+	Tags:       []string{"school"},
+	Parameters: []*openapi3.Parameter{idempotencyKeyParam},
+	Body:       &openapi.Body{Content: jsonContent(resources.School{})},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusCreated,
+			Description: "The new school.",
+			Headers: map[string]string{
+				"Location":            "The path of the new school.",
+				"ETag":                etagHeaderDoc,
+				"Idempotent-Replayed": idempotentReplayedHeaderDoc,
+			},
+			Content: jsonContent(resources.School{}),
+		},
+		invalidSchoolResponse,
+		{
+			Status:      http.StatusConflict,
+			Description: conflictResponse.Description + " " + idempotencyInProgressDoc,
+			Content:     conflictResponse.Content,
+		},
+		{
+			Status:      http.StatusUnprocessableEntity,
+			Description: idempotencyKeyReusedDoc,
+			Content:     jsonContent(resources.Error{}),
+		},
+		internalErrorResponse,
+	},
+}
+
+var importSchoolsDoc = openapi.Operation{
+	Summary: "Import schools in bulk",
+	Description: "Adds the schools in a CSV or JSON Lines file. CSV files start with a " +
+		"header row naming the columns, which have the same names as the " +
+		"fields of a `School`; `name` is required. Every row is validated " +
+		"before any change is made and the rows are applied in a single " +
+		"transaction, so either every row is applied or none are.",
+	Tags: []string{"schools"},
+	Parameters: []*openapi3.Parameter{
+		openapi3.NewQueryParameter(upsertField).
+			WithDescription("Update the active school with the same `external_id` as a row " +
+				"instead of adding a new school. Rows without an `external_id` are " +
+				"always added.").
+			WithSchema(openapi3.NewBoolSchema().WithDefault(false)),
+	},
+	Body: &openapi.Body{
+		Required: true,
+		Content: map[string]interface{}{
+			"text/csv":             openapi3.NewStringSchema(),
+			"application/x-ndjson": openapi3.NewStringSchema(),
+			"application/jsonl":    openapi3.NewStringSchema(),
+		},
+	},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "Every row was applied.",
+			Content:     jsonContent(resources.ImportResult{}),
+		},
+		badRequestResponse,
+		{
+			Status:      http.StatusRequestEntityTooLarge,
+			Description: fmt.Sprintf("The file is larger than %d MiB.", maxImportSize>>20),
+			Content:     jsonContent(resources.Error{}),
+		},
+		{
+			Status:      http.StatusUnsupportedMediaType,
+			Description: "The Content-Type is not one of the supported formats.",
+			Content:     jsonContent(resources.Error{}),
+		},
+		{
+			Status:      http.StatusUnprocessableEntity,
+			Description: "Some rows are invalid. No schools were changed.",
+			Content:     jsonContent(resources.ImportErrors{}),
+		},
+		internalErrorResponse,
+	},
+}
+
+var batchSchoolsDoc = openapi.Operation{
+	Summary: "Create, update and delete schools in one request",
+	Description: "Applies up to 1000 operations, in order. Each operation is checked " +
+		"and applied as the same change made with `POST /schools`, " +
+		"`PUT /schools/{schoolID}` or `DELETE /schools/{schoolID}` would be, and " +
+		"its result has the status, ETag and body of the response to that request. " +
+		"In `atomic` mode, the default, the operations are applied in one " +
+		"transaction: if any of them fails none are applied, the response is a 422 " +
+		"and the other operations have the status 424. In `partial` mode each " +
+		"operation is applied on its own whether or not the others fail, and the " +
+		"response is a 200. Like `POST /schools`, batches can be retried safely " +
+		"with an Idempotency-Key header.",
+	Tags:       []string{"schools"},
+	Parameters: []*openapi3.Parameter{idempotencyKeyParam},
+	Body:       &openapi.Body{Required: true, Content: jsonContent(resources.Batch{})},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "The result of each operation.",
+			Headers:     map[string]string{"Idempotent-Replayed": idempotentReplayedHeaderDoc},
+			Content:     jsonContent(resources.BatchResults{}),
+		},
+		{
+			Status: http.StatusBadRequest,
+			Description: "The body is not a valid batch. `message` describes the first " +
+				"invalid field and `errors` lists every invalid field.",
+			Content: jsonContent(resources.FieldErrors{}),
+		},
+		{
+			Status:      http.StatusConflict,
+			Description: idempotencyInProgressDoc,
+			Content:     jsonContent(resources.Error{}),
+		},
+		{
+			Status: http.StatusUnprocessableEntity,
+			Description: "An operation of an `atomic` batch failed, so none were applied. " +
+				"`results` has the failed operation's error. Also sent, with only a " +
+				"`message`, when the Idempotency-Key has already been used for a " +
+				"request with a different body.",
+			Headers: map[string]string{"Idempotent-Replayed": idempotentReplayedHeaderDoc},
+			Content: jsonContent(resources.BatchResults{}),
+		},
+		internalErrorResponse,
+	},
+}
+
+var exportSchoolsDoc = openapi.Operation{
+	Summary: "Export every school",
+	Description: "Downloads every active school, ordered by id, as a CSV, JSON Lines or " +
+		"JSON file. The file is streamed, so the response has no " +
+		"Content-Length. CSV files have a header row naming the columns, which " +
+		"have the same names as the fields of a `School`, and can be imported " +
+		"again with `POST /schools/import`.",
+	Tags: []string{"schools"},
+	Parameters: []*openapi3.Parameter{
+		openapi3.NewQueryParameter(formatField).
+			WithDescription("The format of the file.").
+			WithSchema(openapi3.NewStringSchema().
+				WithEnum(exporter.FormatCSV, exporter.FormatJSONL, exporter.FormatJSON).
+				WithDefault(formatDefault)),
+		openapi3.NewHeaderParameter("If-None-Match").
+			WithDescription("The ETag of a previous export. If no school has changed since, the " +
+				"export is not sent again.").
+			WithSchema(openapi3.NewStringSchema()),
+		ifModifiedSinceParam,
+	},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "Every active school.",
+			Headers: map[string]string{
+				"ETag":                "Identifies the data in the export. It changes whenever a school is changed.",
+				"Last-Modified":       "The time any school was last changed.",
+				"Cache-Control":       cacheControlHeaderDoc,
+				"Content-Disposition": "Names the file `schools.<format>`.",
+			},
+			Content: map[string]interface{}{
+				"text/csv":             openapi3.NewStringSchema(),
+				"application/x-ndjson": openapi3.NewStringSchema(),
+				gin.MIMEJSON:           []resources.School{},
+			},
+		},
+		{
+			Status:      http.StatusNotModified,
+			Description: "No school has changed since the export identified by If-None-Match or If-Modified-Since.",
+		},
+		badRequestResponse,
+		internalErrorResponse,
+	},
+}
+
+var searchSchoolsDoc = openapi.Operation{
+	Summary: "Search for schools by name",
+	Description: "Returns the schools whose names best match `q`, most relevant first. " +
+		"Matching ignores case, punctuation and words such as \"of\" and \"the\", " +
+		"expands common abbreviations (e.g. \"Univ\" to \"University\" and \"St\" to " +
+		"\"State\" or \"Saint\") and tolerates typos and partially typed words.",
+	Tags: []string{"schools"},
+	Parameters: []*openapi3.Parameter{
+		openapi3.NewQueryParameter(queryField).
+			WithDescription("The name, or part of the name, to search for.").
+			WithRequired(true).
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter(limitField).
+			WithDescription("Maximum results to return").
+			WithSchema(openapi3.NewIntegerSchema().WithMin(minLimit).WithMax(maxLimit).WithDefault(searchLimitDefault)),
+	},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "The matching schools.",
+			Content:     jsonContent(resources.SearchResults{}),
+		},
+		badRequestResponse,
+	},
+}
+
+var autocompleteSchoolsDoc = openapi.Operation{
+	Summary: "Suggest schools for a partially typed name",
+	Description: "Returns schools whose names start with `prefix`, followed by schools " +
+		"with a later word in their name that starts with `prefix` (e.g. " +
+		"\"Wallace\" suggests \"George C Wallace State Community College\"). " +
+		"Matching ignores case and punctuation.",
+	Tags: []string{"schools"},
+	Parameters: []*openapi3.Parameter{
+		openapi3.NewQueryParameter(prefixField).
+			WithDescription("The partially typed name.").
+			WithRequired(true).
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter(limitField).
+			WithDescription("Maximum suggestions to return").
+			WithSchema(openapi3.NewIntegerSchema().WithMin(minLimit).WithMax(maxLimit).WithDefault(searchLimitDefault)),
+	},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "The suggested schools.",
+			Content:     jsonContent(resources.Suggestions{}),
+		},
+		badRequestResponse,
+	},
+}
+
+var getSchoolDoc = openapi.Operation{
+	Summary:     "Get a specific school",
+	Description: "Returns the school referenced by `schoolID` in the path.",
+	Tags:        []string{"school"},
+	Parameters:  []*openapi3.Parameter{schoolIDParam, ifNoneMatchParam, ifModifiedSinceParam},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "A school. CSV responses have a header row and one row.",
+			Headers: map[string]string{
+				"Vary":          varyHeaderDoc,
+				"ETag":          etagHeaderDoc,
+				"Last-Modified": lastModifiedHeaderDoc,
+				"Cache-Control": cacheControlHeaderDoc,
+			},
+			Content: schoolsContent(resources.School{}),
+		},
+		notModifiedResponse,
+		badRequestResponse,
+		notFoundResponse,
+		notAcceptableResponse,
+		goneResponse,
+		internalErrorResponse,
+	},
+}
+
+var updateSchoolDoc = openapi.Operation{
+	Summary:     "Update a specific school",
+	Description: "Updates the school referenced by `schoolID` in the path.",
+	Tags:        []string{"school"},
+	Parameters:  []*openapi3.Parameter{schoolIDParam, ifMatchParam},
+	Body:        &openapi.Body{Content: jsonContent(resources.School{})},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "A school.",
+			Headers:     map[string]string{"ETag": etagHeaderDoc},
+			Content:     jsonContent(resources.School{}),
+		},
+		invalidSchoolResponse,
+		notFoundResponse,
+		conflictResponse,
+		goneResponse,
+		preconditionFailedResponse,
+		preconditionRequiredResponse,
+		internalErrorResponse,
+	},
+}
+
+var patchSchoolDoc = openapi.Operation{
+	Summary: "Change some fields of a specific school",
+	Description: "Applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to " +
+		"the school referenced by `schoolID` in the path, leaving the fields it " +
+		"does not mention unchanged. In a merge patch a field set to `null` is " +
+		"cleared. The patched school is checked against the same rules as the " +
+		"body of `PUT /schools/{schoolID}`. Changes to `id` and `deleted_at` are " +
+		"ignored.",
+	Tags:       []string{"school"},
+	Parameters: []*openapi3.Parameter{schoolIDParam, ifMatchParam},
+	Body: &openapi.Body{
+		Description: "A merge patch object with the fields to change, or a JSON Patch " +
+			"array of the operations to apply in order.",
+		Required: true,
+		Content: map[string]interface{}{
+			mediaMergePatch: openapi3.NewObjectSchema(),
+			mediaJSONPatch: openapi3.NewArraySchema().
+				WithItems(openapi3.NewObjectSchema().
+					WithProperty("op", openapi3.NewStringSchema().
+						WithEnum("add", "remove", "replace", "move", "copy", "test")).
+					WithProperty("path", openapi3.NewStringSchema()).
+					WithProperty("from", openapi3.NewStringSchema()).
+					WithProperty("value", &openapi3.Schema{}).
+					WithRequired([]string{"op", "path"})),
+		},
+	},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "The patched school.",
+			Headers:     map[string]string{"ETag": etagHeaderDoc},
+			Content:     jsonContent(resources.School{}),
+		},
+		{
+			Status: http.StatusBadRequest,
+			Description: "The patch is malformed, the patched school is not valid or a " +
+				"parameter is invalid. For an invalid school `message` describes the " +
+				"first invalid field and `errors` lists every invalid field.",
+			Content: jsonContent(resources.FieldErrors{}),
+		},
+		notFoundResponse,
+		conflictResponse,
+		goneResponse,
+		{
+			Status:      http.StatusUnsupportedMediaType,
+			Description: fmt.Sprintf("The Content-Type is not %s or %s.", mediaMergePatch, mediaJSONPatch),
+			Content:     jsonContent(resources.Error{}),
+		},
+		{
+			Status:      http.StatusUnprocessableEntity,
+			Description: "The JSON Patch cannot be applied to the school, for example because a `test` operation failed. The school was not changed.",
+			Content:     jsonContent(resources.Error{}),
+		},
+		preconditionFailedResponse,
+		preconditionRequiredResponse,
+		internalErrorResponse,
+	},
+}
+
+var deleteSchoolDoc = openapi.Operation{
+	Summary: "Delete a specific school",
+	Description: "Deletes the school referenced by `schoolID` in the path. The ids of " +
+		"the remaining schools do not change. Deleted schools can be restored " +
+		"until they are purged after the configured retention period.",
+	Tags:       []string{"school"},
+	Parameters: []*openapi3.Parameter{schoolIDParam, ifMatchParam},
+	Responses: []openapi.Response{
+		{Status: http.StatusNoContent, Description: "The school was deleted."},
+		badRequestResponse,
+		notFoundResponse,
+		goneResponse,
+		preconditionFailedResponse,
+		preconditionRequiredResponse,
+		internalErrorResponse,
+	},
+}
+
+var restoreSchoolDoc = openapi.Operation{
+	Summary: "Restore a deleted school",
+	Description: "Restores the deleted school referenced by `schoolID` in the path. " +
+		"Restoring a school that is not deleted has no effect.",
+	Tags:       []string{"school"},
+	Parameters: []*openapi3.Parameter{schoolIDParam},
+	Responses: []openapi.Response{
+		{
+			Status:      http.StatusOK,
+			Description: "The restored school.",
+			Headers:     map[string]string{"ETag": etagHeaderDoc},
+			Content:     jsonContent(resources.School{}),
+		},
+		badRequestResponse,
+		notFoundResponse,
+		internalErrorResponse,
+	},
+}
+
+// stringsToValues converts values to the type used for enums.
+func stringsToValues(values []string) []interface{} {
+	enum := make([]interface{}, len(values))
+	for i, value := range values {
+		enum[i] = value
+	}
+	return enum
+}
@@ -1,18 +1,32 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/clinstid/schools_api/cursor"
 	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/exporter"
+	"github.com/clinstid/schools_api/importer"
 	"github.com/clinstid/schools_api/resources"
+	"github.com/clinstid/schools_api/search"
+	"github.com/clinstid/schools_api/validation"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 const (
+	// Path parameter of the /schools/:schoolID routes
+	schoolIDField = "schoolID"
+
 	// Query parameter constants
 	limitField   = "limit"
 	limitDefault = 100
@@ -22,42 +36,340 @@ const (
 	offsetField   = "offset"
 	offsetDefault = 0
 	minOffSet     = 0
+
+	deletedField = "deleted"
+
+	// Name filters applied before pagination
+	queryField  = "q"
+	prefixField = "prefix"
+	exactField  = "exact"
+
+	searchLimitDefault = 10
+
+	sortField   = "sort"
+	sortDefault = "id"
+
+	// Opaque token used instead of offset for keyset pagination
+	cursorField = "cursor"
+
+	// Update schools with matching external ids when importing
+	upsertField = "upsert"
+
+	// maxImportSize is the largest request body accepted by ImportSchools.
+	maxImportSize = 32 << 20
+
+	formatField   = "format"
+	formatDefault = exporter.FormatJSON
 )
 
+// exportTypes maps the formats of ExportSchools to the content types of the
+// responses.
+var exportTypes = map[string]string{
+	exporter.FormatCSV:   "text/csv; charset=utf-8",
+	exporter.FormatJSONL: "application/x-ndjson",
+	exporter.FormatJSON:  "application/json; charset=utf-8",
+}
+
+// importFormats maps the content types accepted by ImportSchools to import
+// formats.
+var importFormats = map[string]string{
+	"text/csv":             importer.FormatCSV,
+	"application/x-ndjson": importer.FormatJSONL,
+	"application/jsonl":    importer.FormatJSONL,
+}
+
 var (
 	// Error messages
 	limitNotNumberErrMsg    = "limit query parameter must be a number"
 	limitOutOfBoundsErrMsg  = fmt.Sprintf("limit query parameter must be at least %d and no greater than %d", minLimit, maxLimit)
 	offsetNotNumberErrMsg   = "offset query parameter must be a number"
 	offsetOutOfBoundsErrMsg = fmt.Sprintf("offset query parameter must be at least %d", minOffSet)
+	deletedNotBoolErrMsg    = "deleted query parameter must be true or false"
+	sortInvalidErrMsg       = fmt.Sprintf("sort query parameter must be one of %s", strings.Join(db.SortValues(), ", "))
+	cursorInvalidErrMsg     = "cursor query parameter is not a valid cursor"
+	cursorSortErrMsg        = "cursor query parameter was issued for a different sort"
+	cursorWithOffsetErrMsg  = "cursor and offset query parameters cannot be combined"
+	upsertNotBoolErrMsg     = "upsert query parameter must be true or false"
+	importTypeErrMsg        = "Content-Type must be text/csv, application/x-ndjson or application/jsonl"
+	importTooLargeErrMsg    = fmt.Sprintf("import must be at most %d bytes", maxImportSize)
+	importInvalidErrMsg     = "import has invalid rows, no schools were changed"
+	formatInvalidErrMsg     = "format query parameter must be one of csv, jsonl, json"
+	notAcceptableErrMsg     = fmt.Sprintf("Accept header must allow one of %s", strings.Join(schoolMediaTypes, ", "))
+	queryRequiredErrMsg     = "q query parameter is required"
+	prefixRequiredErrMsg    = "prefix query parameter is required"
 	schoolIdNotNumberErrMsg = "school id must be a number"
+	patchTypeErrMsg         = fmt.Sprintf("Content-Type must be %s or %s", mediaMergePatch, mediaJSONPatch)
+	mergePatchInvalidErrMsg = "merge patch must be a JSON object"
+	jsonPatchInvalidErrMsg  = "JSON patch must be an array of operations"
+	patchFailedErrMsg       = "patch cannot be applied to the school"
+	patchNotObjectErrMsg    = "patch must leave the school a JSON object"
+	ifMatchRequiredErrMsg   = "If-Match header is required to change a school"
+	ifMatchFailedErrMsg     = "If-Match does not match the current ETag of the school"
+	internalErrMsg          = "internal server error"
+
+	// Error messages of Handler.Idempotent
+	idempotencyKeyTooLongErrMsg    = fmt.Sprintf("Idempotency-Key header must be at most %d characters", maxIdempotencyKeyLength)
+	idempotencyKeyReusedErrMsg     = "Idempotency-Key has already been used for a different request"
+	idempotencyKeyInProgressErrMsg = "a request with the same Idempotency-Key is still in progress"
+
+	// Error messages of BatchSchools
+	batchOpInvalidErrMsg      = fmt.Sprintf("op must be one of %s, %s, %s", resources.BatchCreate, resources.BatchUpdate, resources.BatchDelete)
+	batchIDRequiredErrMsg     = "id is required to update or delete a school"
+	batchSchoolRequiredErrMsg = "school is required to create or update a school"
+	batchFailedErrMsg         = "operation %d failed, no schools were changed"
+	batchNotAppliedErrMsg     = "not applied because operation %d failed"
+	batchConflictErrMsg       = "School created by operation %d already has the name %q"
 )
 
-// buildErrorResponse returns a gin.H struct with a message property that will
-// look like the following when renderd as JSON:
+// Options configures the behavior of a Handler.
+type Options struct {
+	// RequireIfMatch rejects requests that change a school without an
+	// If-Match header with a 428 response, so that clients cannot overwrite
+	// changes they have not seen.
+	RequireIfMatch bool
+
+	// IdempotencyKeys holds the responses to requests with an
+	// Idempotency-Key header for IdempotencyTTL, see Handler.Idempotent.
+	// The header is ignored when it is nil. A key is held by a request in
+	// progress for IdempotencyLease at most, so that its retries are not
+	// turned away for long if it never finishes.
+	IdempotencyKeys  db.IdempotencyStore
+	IdempotencyTTL   time.Duration
+	IdempotencyLease time.Duration
+}
+
+// Handler holds the dependencies shared by the handler functions for the
+// schools API.
+type Handler struct {
+	store   db.SchoolStore
+	index   *search.Index
+	cursors *cursor.Codec
+	options Options
+}
+
+// New returns a Handler that uses store to look up and modify schools, index
+// to search for schools by name and cursors to sign pagination cursors.
+func New(store db.SchoolStore, index *search.Index, cursors *cursor.Codec, options Options) *Handler {
+	return &Handler{store: store, index: index, cursors: cursors, options: options}
+}
+
+// buildErrorResponse returns a resources.Error with a message property that
+// will look like the following when renderd as JSON:
 //
 // {
 //   "message": "Error message"
 // }
-func buildErrorResponse(message string) gin.H {
-	return gin.H{"message": message}
+func buildErrorResponse(message string) resources.Error {
+	return resources.Error{Message: message}
 }
 
 // buildBindErrorResponse builds a custom error response with the error type
 // returned from a Bind call used to deserialize a request body into an
-// internal structure.
-func buildBindErrorResponse(err error) gin.H {
+// internal structure. Errors with specific fields also list each field and
+// what is wrong with it:
+//
+// {
+//   "message": "Field \"name\" is required",
+//   "errors": [
+//     {
+//       "field": "name",
+//       "message": "Field \"name\" is required"
+//     }
+//   ]
+// }
+func buildBindErrorResponse(err error) resources.FieldErrors {
 	switch actualErr := err.(type) {
 	case *json.UnmarshalTypeError:
 		msg := fmt.Sprintf("Field %q must be a %s", actualErr.Field, actualErr.Type)
-		return buildErrorResponse(msg)
+		return resources.FieldErrors{
+			Message: msg,
+			Errors:  []resources.FieldError{{Field: actualErr.Field, Message: msg}},
+		}
+	case validator.ValidationErrors:
+		resp := resources.FieldErrors{Message: validation.Message(actualErr)}
+		for _, fe := range validation.Fields(actualErr) {
+			resp.Errors = append(resp.Errors, resources.FieldError{Field: fe.Field, Message: fe.Message})
+		}
+		return resp
 	default:
-		return buildErrorResponse(err.Error())
+		return resources.FieldErrors{Message: err.Error()}
 	}
+}
 
+// bindSchool binds the school in the request body to school, which trims and
+// validates it. If the body is not a valid school a 400 response is sent and
+// false is returned.
+func bindSchool(c *gin.Context, school *resources.School) bool {
+	if err := c.ShouldBind(school); err != nil {
+		c.JSON(http.StatusBadRequest, buildBindErrorResponse(err))
+		return false
+	}
+	return true
 }
 
-// buildSchoolLink returns a URL for the current school
+// buildStoreErrorResponse maps an error returned by the db.SchoolStore to an
+// HTTP status code and error response. Conflicts also return the id of the
+// school that already has the name and a link to it:
+//
+// {
+//   "message": "School with id 12 already has the name \"Auburn University\"",
+//   "id": 12,
+//   "link": "http://.../schools/12"
+// }
+func buildStoreErrorResponse(r *http.Request, err error) (int, interface{}) {
+	switch actualErr := err.(type) {
+	case *db.ConflictError:
+		return http.StatusConflict, resources.Conflict{
+			Message: err.Error(),
+			ID:      actualErr.ID,
+			Link:    buildSchoolLink(r, actualErr.ID),
+		}
+	case *db.NotFoundError:
+		return http.StatusNotFound, buildErrorResponse(err.Error())
+	case *db.GoneError:
+		return http.StatusGone, buildErrorResponse(err.Error())
+	case *db.VersionMismatchError:
+		return http.StatusPreconditionFailed, buildErrorResponse(err.Error())
+	default:
+		return http.StatusInternalServerError, buildErrorResponse(internalErrMsg)
+	}
+}
+
+// etagMatches reports whether the If-None-Match header value, a list of
+// entity tags or "*", matches etag. Entity tags are compared weakly, ignoring
+// any W/ prefix, as is required for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatches reports whether the If-Match header value, a list of entity tags
+// or "*", matches etag. Entity tags are compared strongly, so weak tags never
+// match, as is required for If-Match.
+func ifMatches(ifMatch, etag string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (tag == etag && !strings.HasPrefix(tag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// schoolETag returns the entity tag of the current version of school in a
+// store with the specified epoch. The versions of the schools of a store that
+// has been recreated start again from 1, so the epoch keeps their entity tags
+// from matching those of the schools it had before.
+func schoolETag(epoch string, school *db.School) string {
+	return fmt.Sprintf(`"%s-%d"`, epoch, school.Version)
+}
+
+// epoch returns the epoch of the store, which is part of the ETag of every
+// school. If it cannot be read an error response is sent and false is
+// returned.
+func (h *Handler) epoch(c *gin.Context) (string, bool) {
+	rev, err := h.store.Revision(c.Request.Context())
+	if err != nil {
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return "", false
+	}
+	return rev.Epoch, true
+}
+
+// errIfMatchRequired is returned by checkIfMatch when a change has no If-Match
+// header and Options.RequireIfMatch is set.
+var errIfMatchRequired = errors.New(ifMatchRequiredErrMsg)
+
+// ifMatchFailedError is returned by checkIfMatch when If-Match does not match
+// the current version of a school.
+type ifMatchFailedError struct {
+	// etag is the current ETag of the school.
+	etag string
+}
+
+func (e *ifMatchFailedError) Error() string {
+	return ifMatchFailedErrMsg
+}
+
+// checkIfMatch returns the version of the school with the specified id in
+// store that ifMatch, the value of an If-Match header, matches, or zero if
+// ifMatch is empty or "*" and any version may be changed. The version is
+// passed to the store, so the change fails if the school is changed again in
+// the meantime. An *ifMatchFailedError is returned if ifMatch does not match
+// the current version of the school and errIfMatchRequired if it is empty and
+// Options.RequireIfMatch is set.
+func (h *Handler) checkIfMatch(ctx context.Context, store db.SchoolStore, schoolID int, ifMatch string) (int64, error) {
+	switch {
+	case ifMatch == "" && h.options.RequireIfMatch:
+		return 0, errIfMatchRequired
+	case ifMatch == "" || strings.TrimSpace(ifMatch) == "*":
+		return 0, nil
+	}
+
+	school, err := store.GetSchool(ctx, schoolID)
+	if err != nil {
+		return 0, err
+	}
+	rev, err := store.Revision(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if etag := schoolETag(rev.Epoch, school); !schoolMatches(ifMatch, etag) {
+		return 0, &ifMatchFailedError{etag: etag}
+	}
+	return school.Version, nil
+}
+
+// buildIfMatchErrorResponse returns the status and body of the response to a
+// change that failed with an error returned by checkIfMatch.
+func buildIfMatchErrorResponse(r *http.Request, err error) (int, interface{}) {
+	switch err.(type) {
+	case *ifMatchFailedError:
+		return http.StatusPreconditionFailed, buildErrorResponse(ifMatchFailedErrMsg)
+	default:
+		if err == errIfMatchRequired {
+			return http.StatusPreconditionRequired, buildErrorResponse(ifMatchRequiredErrMsg)
+		}
+		return buildStoreErrorResponse(r, err)
+	}
+}
+
+// matchVersion checks the If-Match header of a request to change the school
+// with the specified id, see checkIfMatch, and returns the version of the
+// school to change. If the check fails an error response, with the current
+// ETag of the school when it is known, is sent and false is returned.
+func (h *Handler) matchVersion(c *gin.Context, schoolID int) (int64, bool) {
+	version, err := h.checkIfMatch(c.Request.Context(), h.store, schoolID, c.GetHeader("If-Match"))
+	if err != nil {
+		if failed, ok := err.(*ifMatchFailedError); ok {
+			c.Header("ETag", failed.etag)
+		}
+		c.JSON(buildIfMatchErrorResponse(c.Request, err))
+		return 0, false
+	}
+	return version, true
+}
+
+// abortResponse closes the connection of a response that failed after part
+// of its body was sent, so that the client sees that the response is
+// incomplete instead of receiving a truncated body that looks complete.
+func abortResponse(c *gin.Context, err error) {
+	log.Printf("aborting response to %s %s: %v", c.Request.Method, c.Request.URL, err)
+	if hj, ok := c.Writer.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+	c.Abort()
+}
+
+// buildSchoolLink returns a URL for the school with the specified id on the
+// host the request was sent to.
 func buildSchoolLink(r *http.Request, schoolID int) string {
 	var scheme string
 	if r.TLS == nil {
@@ -67,7 +379,7 @@ func buildSchoolLink(r *http.Request, schoolID int) string {
 	}
 
 	host := r.Host
-	path := fmt.Sprintf("%s/%d", r.URL.Path, schoolID)
+	path := fmt.Sprintf("/schools/%d", schoolID)
 
 	link := &url.URL{
 		Scheme: scheme,
@@ -80,10 +392,31 @@ func buildSchoolLink(r *http.Request, schoolID int) string {
 }
 
 // buildListSchoolsLink returns a URL for making a ListSchools request with the
-// offset and limit encoded as query parameters. This function is used for
-// building the next, prev, first, and last links that are returned in a
-// ListSchools response.
+// offset and limit encoded as query parameters. Any other query parameters in
+// the current request are carried over. This function is used for building
+// the next, prev, first, and last links that are returned in a ListSchools
+// response.
 func buildListSchoolsLink(r *http.Request, offset int, limit int) string {
+	q := r.URL.Query()
+	q.Set(offsetField, strconv.Itoa(offset))
+	q.Set(limitField, strconv.Itoa(limit))
+	return buildListLink(r, q)
+}
+
+// buildListSchoolsCursorLink returns a URL for making a ListSchools request
+// with the cursor token and limit encoded as query parameters. An empty token
+// requests the first page. Any other query parameters in the current request
+// are carried over.
+func buildListSchoolsCursorLink(r *http.Request, token string, limit int) string {
+	q := r.URL.Query()
+	q.Set(cursorField, token)
+	q.Set(limitField, strconv.Itoa(limit))
+	return buildListLink(r, q)
+}
+
+// buildListLink returns a URL for the current path with the query parameters
+// in q.
+func buildListLink(r *http.Request, q url.Values) string {
 	var scheme string
 	if r.TLS == nil {
 		scheme = "http"
@@ -100,16 +433,21 @@ func buildListSchoolsLink(r *http.Request, offset int, limit int) string {
 		Path:   path,
 	}
 
-	q := link.Query()
-	q.Set(offsetField, strconv.Itoa(offset))
-	q.Set(limitField, strconv.Itoa(limit))
 	link.RawQuery = q.Encode()
 	return link.String()
 }
 
 // ListSchools is a handler function for for the ListSchools operation. It
 // takes query parameters `limit` and `offset` to determine what schools in the
-// list to return. The format of the response looks like:
+// list to return. The `q`, `prefix` and `exact` query parameters filter the
+// schools by name, case-insensitively, before they are paginated. The `sort`
+// query parameter orders the schools by `id` (the default) or `name`, prefixed
+// with `-` for descending order. When the `deleted` query parameter is true the
+// deleted schools are listed instead of the active ones. Passing the `cursor`
+// query parameter, empty for the first page, paginates with the signed
+// `next_cursor` returned in `meta` instead of `offset`, so that schools added
+// or removed between requests do not shift the pages. The format of the
+// response looks like:
 //
 // ```json
 // {
@@ -133,9 +471,24 @@ func buildListSchoolsLink(r *http.Request, offset int, limit int) string {
 // ```
 //
 // `schools` is an array of school objects with a name and an id
-// `meta` contains meta data about the collection including the total number of schools
+// `meta` contains meta data about the collection including the total number
+// of schools matching the filters
 // `links` has URLs for first, last, next, and previous pages of schools
-func ListSchools(c *gin.Context) {
+//
+// The Accept header selects XML or YAML with the same structure, or CSV or
+// NDJSON, which hold only the schools and move the total and links to the
+// X-Total-Count and Link headers.
+//
+// The ETag of the response changes whenever any school changes and
+// Last-Modified is the time of the latest change, so clients can send either
+// in If-None-Match or If-Modified-Since to get a 304 response instead of an
+// unchanged page.
+func (h *Handler) ListSchools(c *gin.Context) {
+	mediaType := negotiateSchools(c)
+	if mediaType == "" {
+		return
+	}
+
 	// Parse query parameters
 	limit, err := strconv.Atoi(c.DefaultQuery(limitField, strconv.Itoa(limitDefault)))
 	if err != nil {
@@ -148,6 +501,14 @@ func ListSchools(c *gin.Context) {
 		return
 	}
 
+	// Clients opt in to cursor pagination by passing a cursor, which is
+	// empty for the first page.
+	token, useCursor := c.GetQuery(cursorField)
+	if _, ok := c.GetQuery(offsetField); ok && useCursor {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(cursorWithOffsetErrMsg))
+		return
+	}
+
 	offset, err := strconv.Atoi(c.DefaultQuery(offsetField, strconv.Itoa(offsetDefault)))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, buildErrorResponse(offsetNotNumberErrMsg))
@@ -159,8 +520,72 @@ func ListSchools(c *gin.Context) {
 		return
 	}
 
+	deleted, err := strconv.ParseBool(c.DefaultQuery(deletedField, "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(deletedNotBoolErrMsg))
+		return
+	}
+
+	sort, err := db.ParseSort(c.DefaultQuery(sortField, sortDefault))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(sortInvalidErrMsg))
+		return
+	}
+
 	// Retrieve the slice of schools for the given limit and offset.
-	sResult := db.GetSchools(limit, offset)
+	opts := db.ListOptions{
+		Limit:   limit,
+		Offset:  offset,
+		Deleted: deleted,
+		Sort:    sort,
+		Filter: db.NameFilter{
+			Contains: c.Query(queryField),
+			Prefix:   c.Query(prefixField),
+			Exact:    c.Query(exactField),
+		},
+	}
+
+	if useCursor {
+		if token != "" {
+			cur, err := h.cursors.Decode(token)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, buildErrorResponse(cursorInvalidErrMsg))
+				return
+			}
+			if cur.Sort != sort.String() {
+				c.JSON(http.StatusBadRequest, buildErrorResponse(cursorSortErrMsg))
+				return
+			}
+			opts.After = &db.Position{ID: cur.ID, Name: cur.Name}
+		}
+
+		// Fetch one extra school to find out if there is a next page
+		opts.Limit++
+	}
+
+	// The revision is read before the schools, so a change made in between
+	// leaves the response with a stale ETag rather than a stale body.
+	rev, err := h.store.Revision(c.Request.Context())
+	if err != nil {
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	etag := collectionETag(c.Request, rev, mediaType)
+	if notModified(c, etag, rev.ModifiedAt) {
+		return
+	}
+
+	sResult, err := h.store.GetSchools(c.Request.Context(), opts)
+	if err != nil {
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	setValidators(c, etag, rev.ModifiedAt)
+
+	if useCursor {
+		h.listSchoolsByCursor(c, mediaType, sResult, sort, limit)
+		return
+	}
 
 	// Build links for pagination
 	firstLink := buildListSchoolsLink(c.Request, 0, limit)
@@ -197,12 +622,55 @@ func ListSchools(c *gin.Context) {
 		},
 	}
 	// Add the schools to the response object
-	for idx, s := range sResult.Schools {
-		schools.Schools = append(schools.Schools, resources.School{ID: offset + idx, Name: s})
+	for i := range sResult.Schools {
+		schools.Schools = append(schools.Schools, resources.NewSchool(&sResult.Schools[i]))
 	}
 
 	// Render and return the response
-	c.JSON(http.StatusOK, schools)
+	renderSchoolsPage(c, mediaType, schools)
+}
+
+// listSchoolsByCursor renders the response to a ListSchools request using
+// cursor pagination. sResult holds up to limit+1 schools, the extra school
+// showing that there is a next page. Only first and next links are returned
+// because cursors can only move forward.
+func (h *Handler) listSchoolsByCursor(c *gin.Context, mediaType string, sResult db.SchoolsResult, sort db.Sort, limit int) {
+	page := sResult.Schools
+	var nextCursor, nextLink string
+	if len(page) > limit {
+		page = page[:limit]
+		last := page[limit-1]
+		cur := cursor.Cursor{Sort: sort.String(), ID: last.ID}
+		if sort.Field == db.SortByName {
+			cur.Name = last.Name
+		}
+		nextCursor = h.cursors.Encode(cur)
+		nextLink = buildListSchoolsCursorLink(c.Request, nextCursor, limit)
+	}
+
+	schools := resources.Schools{
+		Schools: make([]resources.School, 0, len(page)),
+		Meta:    resources.Meta{Total: sResult.Total, NextCursor: nextCursor},
+		Links: resources.Links{
+			First: buildListSchoolsCursorLink(c.Request, "", limit),
+			Next:  nextLink,
+		},
+	}
+	for i := range page {
+		schools.Schools = append(schools.Schools, resources.NewSchool(&page[i]))
+	}
+
+	renderSchoolsPage(c, mediaType, schools)
+}
+
+// renderSchoolsPage renders a page of a ListSchools response as mediaType.
+// The meta and links are moved to response headers for media types that
+// have no room for them in the body.
+func renderSchoolsPage(c *gin.Context, mediaType string, schools resources.Schools) {
+	if mediaType == mediaCSV || mediaType == mediaNDJSON {
+		setPageHeaders(c, schools.Meta, schools.Links)
+	}
+	renderSchools(c, mediaType, schools, schools.Schools)
 }
 
 // AddSchool adds a new school to the list. It takes a new school object in the
@@ -212,47 +680,78 @@ func ListSchools(c *gin.Context) {
 // {
 // 	 "name": "New School Name"
 // }```
-func AddSchool(c *gin.Context) {
+//
+// A 409 response is returned if another school already has the same name,
+// ignoring case, whitespace and punctuation. Deleted schools keep their names
+// until they are purged.
+func (h *Handler) AddSchool(c *gin.Context) {
 	var school resources.School
-	err := c.ShouldBind(&school)
+	if !bindSchool(c, &school) {
+		return
+	}
+	epoch, ok := h.epoch(c)
+	if !ok {
+		return
+	}
+
+	added, err := h.store.AddSchool(c.Request.Context(), school.StoreSchool(0))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, buildBindErrorResponse(err))
+		c.JSON(buildStoreErrorResponse(c.Request, err))
 		return
 	}
 
-	schoolID := db.AddSchool(school.Name)
-	c.Header("Location", buildSchoolLink(c.Request, schoolID))
-	c.JSON(http.StatusCreated, resources.School{ID: schoolID, Name: school.Name})
+	c.Header("Location", buildSchoolLink(c.Request, added.ID))
+	c.Header("ETag", schoolETag(epoch, added))
+	c.JSON(http.StatusCreated, resources.NewSchool(added))
 }
 
-// GetSchool retrieves a single school with the specified id.
-func GetSchool(c *gin.Context) {
+// GetSchool retrieves a single school with the specified id. It is rendered
+// as JSON, CSV, XML, YAML or NDJSON as selected by the Accept header. A 304
+// response is returned if If-None-Match matches the ETag of the school or, in
+// its absence, the school has not changed since If-Modified-Since.
+func (h *Handler) GetSchool(c *gin.Context) {
+	mediaType := negotiateSchools(c)
+	if mediaType == "" {
+		return
+	}
+
 	// Get the id from the path
-	schoolID, err := strconv.Atoi(c.Param("schoolID"))
+	schoolID, err := strconv.Atoi(c.Param(schoolIDField))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, buildErrorResponse(schoolIdNotNumberErrMsg))
 		return
 	}
 
 	// Look up the school in the database
-	schoolName, err := db.GetSchool(schoolID)
+	school, err := h.store.GetSchool(c.Request.Context(), schoolID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, buildErrorResponse(err.Error()))
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	epoch, ok := h.epoch(c)
+	if !ok {
 		return
 	}
 
-	// Build the response object
-	school := resources.School{ID: schoolID, Name: *schoolName}
+	etag := representationETag(schoolETag(epoch, school), mediaType)
+	if notModified(c, etag, school.UpdatedAt) {
+		return
+	}
+	setValidators(c, etag, school.UpdatedAt)
 
 	// Render the response object
-	c.JSON(http.StatusOK, school)
+	resp := resources.NewSchool(school)
+	renderSchools(c, mediaType, resp, []resources.School{resp})
 }
 
 // UpdateSchool updates a single school with the specified id. The body
-// contains the new name for the school.
-func UpdateSchool(c *gin.Context) {
+// contains the new name for the school. A 409 response is returned if the
+// school is renamed to the name of another school. If the request has an
+// If-Match header the school is only updated if the header matches its ETag,
+// otherwise a 412 response is returned.
+func (h *Handler) UpdateSchool(c *gin.Context) {
 	// Get the id from the path
-	schoolID, err := strconv.Atoi(c.Param("schoolID"))
+	schoolID, err := strconv.Atoi(c.Param(schoolIDField))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, buildErrorResponse(schoolIdNotNumberErrMsg))
 		return
@@ -260,19 +759,378 @@ func UpdateSchool(c *gin.Context) {
 
 	// Deserialize the JSON body and bind it to the resources.School struct
 	var school resources.School
-	err = c.ShouldBind(&school)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, buildBindErrorResponse(err))
+	if !bindSchool(c, &school) {
+		return
+	}
+
+	version, ok := h.matchVersion(c, schoolID)
+	if !ok {
 		return
 	}
 
 	// Update the school in the database
-	_, err = db.UpdateSchool(schoolID, school.Name)
+	record := school.StoreSchool(schoolID)
+	record.Version = version
+	updated, err := h.store.UpdateSchool(c.Request.Context(), record)
+	if err != nil {
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	epoch, ok := h.epoch(c)
+	if !ok {
+		return
+	}
+
+	// Render the response
+	c.Header("ETag", schoolETag(epoch, updated))
+	c.JSON(http.StatusOK, resources.NewSchool(updated))
+}
+
+// PatchSchool changes some of the fields of the school with the specified
+// id, leaving the others as they are. The body is either a JSON Merge Patch
+// (RFC 7396), with the Content-Type application/merge-patch+json:
+//
+// ```json
+// {
+//   "city": "Auburn",
+//   "website": null
+// }
+// ```
+//
+// or a JSON Patch (RFC 6902), with the Content-Type
+// application/json-patch+json:
+//
+// ```json
+// [
+//   { "op": "test", "path": "/name", "value": "Auburn University" },
+//   { "op": "replace", "path": "/city", "value": "Auburn" }
+// ]
+// ```
+//
+// The patched school is checked against the same rules as the body of
+// UpdateSchool. Changes to the id and deleted_at fields are ignored. A 422
+// response is returned if a JSON Patch cannot be applied, for example because
+// a test operation fails, and a 415 response for other types of body. Like
+// UpdateSchool, it honours the If-Match header.
+func (h *Handler) PatchSchool(c *gin.Context) {
+	// Get the id from the path
+	schoolID, err := strconv.Atoi(c.Param(schoolIDField))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(schoolIdNotNumberErrMsg))
+		return
+	}
+
+	mediaType := c.ContentType()
+	if mediaType != mediaMergePatch && mediaType != mediaJSONPatch {
+		c.JSON(http.StatusUnsupportedMediaType, buildErrorResponse(patchTypeErrMsg))
+		return
+	}
+	patch, err := c.GetRawData()
 	if err != nil {
-		c.JSON(http.StatusNotFound, buildErrorResponse(err.Error()))
+		c.JSON(http.StatusBadRequest, buildErrorResponse(err.Error()))
+		return
+	}
+
+	version, ok := h.matchVersion(c, schoolID)
+	if !ok {
+		return
+	}
+
+	// Read, patch and write back the school in one transaction. The school
+	// is only written back at the version that was patched, so concurrent
+	// changes to the school are not lost.
+	var updated *db.School
+	err = h.store.InTransaction(c.Request.Context(), func(tx db.SchoolStore) error {
+		current, err := tx.GetSchool(c.Request.Context(), schoolID)
+		if err != nil {
+			return err
+		}
+		if version != 0 && current.Version != version {
+			return &db.VersionMismatchError{ID: schoolID, Version: version}
+		}
+		school, err := patchSchool(resources.NewSchool(current), mediaType, patch)
+		if err != nil {
+			return err
+		}
+		record := school.StoreSchool(schoolID)
+		record.Version = current.Version
+		updated, err = tx.UpdateSchool(c.Request.Context(), record)
+		return err
+	})
+	switch err.(type) {
+	case nil:
+	case *patchInvalidError:
+		c.JSON(http.StatusBadRequest, buildErrorResponse(err.Error()))
+		return
+	case *patchFailedError:
+		c.JSON(http.StatusUnprocessableEntity, buildErrorResponse(err.Error()))
+		return
+	case *json.UnmarshalTypeError, validator.ValidationErrors:
+		c.JSON(http.StatusBadRequest, buildBindErrorResponse(err))
+		return
+	default:
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	epoch, ok := h.epoch(c)
+	if !ok {
 		return
 	}
 
 	// Render the response
-	c.JSON(http.StatusOK, resources.School{ID: schoolID, Name: school.Name})
+	c.Header("ETag", schoolETag(epoch, updated))
+	c.JSON(http.StatusOK, resources.NewSchool(updated))
+}
+
+// DeleteSchool soft deletes the school with the specified id. The school can
+// be brought back with RestoreSchool until its tombstone is purged. A
+// successful delete has an empty response body. Like UpdateSchool, it honours
+// the If-Match header.
+func (h *Handler) DeleteSchool(c *gin.Context) {
+	// Get the id from the path
+	schoolID, err := strconv.Atoi(c.Param(schoolIDField))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(schoolIdNotNumberErrMsg))
+		return
+	}
+
+	version, ok := h.matchVersion(c, schoolID)
+	if !ok {
+		return
+	}
+
+	// Delete the school from the database
+	err = h.store.DeleteSchool(c.Request.Context(), schoolID, version)
+	if err != nil {
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RestoreSchool undoes the deletion of the school with the specified id and
+// returns the restored school.
+func (h *Handler) RestoreSchool(c *gin.Context) {
+	// Get the id from the path
+	schoolID, err := strconv.Atoi(c.Param(schoolIDField))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(schoolIdNotNumberErrMsg))
+		return
+	}
+
+	// Restore the school in the database
+	school, err := h.store.RestoreSchool(c.Request.Context(), schoolID)
+	if err != nil {
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	epoch, ok := h.epoch(c)
+	if !ok {
+		return
+	}
+
+	c.Header("ETag", schoolETag(epoch, school))
+	c.JSON(http.StatusOK, resources.NewSchool(school))
+}
+
+// SearchSchools finds schools whose names match the `q` query parameter,
+// tolerating typos and common abbreviations. Up to `limit` schools are
+// returned, most relevant first, each with a relevance score between 0 and 1:
+//
+// ```json
+// {
+//   "results": [
+//     {
+//       "id": 3,
+//       "name": "University of Alabama in Huntsville",
+//       "score": 0.973
+//     },
+//     ...
+//   ],
+//   "meta": {
+//     "total": 12
+//   }
+// }
+// ```
+func (h *Handler) SearchSchools(c *gin.Context) {
+	query := c.Query(queryField)
+	if query == "" {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(queryRequiredErrMsg))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery(limitField, strconv.Itoa(searchLimitDefault)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(limitNotNumberErrMsg))
+		return
+	}
+
+	if limit < minLimit || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(limitOutOfBoundsErrMsg))
+		return
+	}
+
+	matches, total := h.index.Search(query, limit)
+
+	results := resources.SearchResults{
+		Results: make([]resources.SearchResult, 0, len(matches)),
+		Meta:    resources.Meta{Total: total},
+	}
+	for _, m := range matches {
+		results.Results = append(results.Results, resources.SearchResult{ID: m.ID, Name: m.Name, Score: m.Score})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// AutocompleteSchools suggests up to `limit` schools for the partially typed
+// name in the `prefix` query parameter. Schools whose names start with the
+// prefix come first, followed by schools with a later word in their name that
+// starts with the prefix:
+//
+// ```json
+// {
+//   "suggestions": [
+//     {
+//       "id": 20,
+//       "name": "George C Wallace State Community College-Dothan"
+//     },
+//     ...
+//   ]
+// }
+// ```
+func (h *Handler) AutocompleteSchools(c *gin.Context) {
+	prefix := c.Query(prefixField)
+	if strings.TrimSpace(prefix) == "" {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(prefixRequiredErrMsg))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery(limitField, strconv.Itoa(searchLimitDefault)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(limitNotNumberErrMsg))
+		return
+	}
+
+	if limit < minLimit || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(limitOutOfBoundsErrMsg))
+		return
+	}
+
+	matches := h.index.Autocomplete(prefix, limit)
+
+	suggestions := resources.Suggestions{
+		Suggestions: make([]resources.School, 0, len(matches)),
+	}
+	for _, m := range matches {
+		suggestions.Suggestions = append(suggestions.Suggestions, resources.School{ID: m.ID, Name: m.Name})
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// ImportSchools adds schools in bulk from a CSV or JSON Lines request body,
+// selected by the Content-Type header. CSV bodies start with a header row
+// naming the columns, which have the same names as the fields of a school.
+// When the `upsert` query parameter is true, rows with the same
+// `external_id` as an existing school update it instead of adding a new
+// school. Either every row is applied or, if any row is invalid, none are and
+// the response lists the problem with each invalid row:
+//
+// ```json
+// {
+//   "message": "import has invalid rows, no schools were changed",
+//   "errors": [
+//     {
+//       "line": 3,
+//       "field": "type",
+//       "message": "Field \"type\" must be one of public, private, for-profit"
+//     }
+//   ]
+// }
+// ```
+func (h *Handler) ImportSchools(c *gin.Context) {
+	format, ok := importFormats[c.ContentType()]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, buildErrorResponse(importTypeErrMsg))
+		return
+	}
+
+	upsert, err := strconv.ParseBool(c.DefaultQuery(upsertField, "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(upsertNotBoolErrMsg))
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	result, err := importer.Import(c.Request.Context(), h.store, body, format, importer.Options{Upsert: upsert})
+	var tooLarge *http.MaxBytesError
+	switch actualErr := err.(type) {
+	case nil:
+	case *importer.InvalidRowsError:
+		resp := resources.ImportErrors{Message: importInvalidErrMsg}
+		for _, rowErr := range actualErr.Errors {
+			resp.Errors = append(resp.Errors, resources.RowError{
+				Line:    rowErr.Line,
+				Field:   rowErr.Field,
+				Message: rowErr.Message,
+			})
+		}
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return
+	default:
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, buildErrorResponse(importTooLargeErrMsg))
+			return
+		}
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, resources.ImportResult{Created: result.Created, Updated: result.Updated})
+}
+
+// ExportSchools streams every active school, ordered by id, in the format
+// selected by the `format` query parameter: `csv` with a header row, `jsonl`
+// with one school per line or `json` (the default) as an array. The response
+// has an ETag that changes whenever any school changes, so clients can send
+// it in If-None-Match to skip downloading an unchanged export.
+func (h *Handler) ExportSchools(c *gin.Context) {
+	format := c.DefaultQuery(formatField, formatDefault)
+	contentType, ok := exportTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, buildErrorResponse(formatInvalidErrMsg))
+		return
+	}
+
+	rev, err := h.store.Revision(c.Request.Context())
+	if err != nil {
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	etag := fmt.Sprintf(`"%s-%d-%s"`, rev.Epoch, rev.Number, format)
+	if notModified(c, etag, rev.ModifiedAt) {
+		return
+	}
+
+	setValidators(c, etag, rev.ModifiedAt)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="schools.%s"`, format))
+	c.Status(http.StatusOK)
+	err = exporter.Export(c.Request.Context(), h.store, c.Writer, format)
+	if err == nil {
+		return
+	}
+
+	// Nothing is sent until the first batch of schools has been read, so
+	// failures to read it can still be reported normally.
+	if !c.Writer.Written() {
+		for _, header := range []string{"Content-Type", "Content-Disposition", "ETag", "Last-Modified", "Cache-Control"} {
+			c.Writer.Header().Del(header)
+		}
+		c.JSON(buildStoreErrorResponse(c.Request, err))
+		return
+	}
+	abortResponse(c, err)
 }
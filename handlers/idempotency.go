@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/clinstid/schools_api/db"
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotencyKeyLength is the length of the longest Idempotency-Key header
+// accepted.
+const maxIdempotencyKeyLength = 255
+
+// requestFingerprint returns a hash of the method, path and body of r, which
+// is compared to tell a repeated request from a different request made with
+// the same idempotency key.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotent is middleware that makes the handlers that follow it idempotent
+// for requests with an Idempotency-Key header. The status, headers and body
+// of the first response to a request with a key are stored until
+// Options.IdempotencyTTL has passed, and repeats of the request are answered
+// with the stored response and an Idempotent-Replayed header instead of being
+// handled again. Server errors are not stored, so requests that fail with one
+// can be retried. A 422 response is sent if the key has been used for a
+// different request and a 409 response if the request it was used for is
+// still in progress, which it is taken to be for Options.IdempotencyLease at
+// most. Requests without the header are handled as usual.
+func (h *Handler) Idempotent(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" || h.options.IdempotencyKeys == nil {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, buildErrorResponse(idempotencyKeyTooLongErrMsg))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, buildErrorResponse(err.Error()))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	keys := h.options.IdempotencyKeys
+	fingerprint := requestFingerprint(c.Request, body)
+	record, started, err := keys.BeginRequest(c.Request.Context(), key, fingerprint, time.Now().Add(h.options.IdempotencyLease))
+	switch {
+	case err != nil:
+		c.AbortWithStatusJSON(buildStoreErrorResponse(c.Request, err))
+		return
+	case started:
+	case record.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, buildErrorResponse(idempotencyKeyReusedErrMsg))
+		return
+	case record.Response == nil:
+		c.AbortWithStatusJSON(http.StatusConflict, buildErrorResponse(idempotencyKeyInProgressErrMsg))
+		return
+	default:
+		replayResponse(c, record.Response)
+		return
+	}
+
+	w := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	defer func() {
+		c.Writer = w.ResponseWriter
+
+		// The outcome is recorded even if the client has gone away, so that
+		// its retries are answered. A request whose handler panics is
+		// abandoned before the panic is passed on, since it is answered
+		// with a server error too.
+		ctx := context.Background()
+		p := recover()
+		var err error
+		if p != nil || w.Status() >= http.StatusInternalServerError {
+			err = keys.AbandonRequest(ctx, key)
+		} else {
+			err = keys.CompleteRequest(ctx, key, db.StoredResponse{
+				Status: w.Status(),
+				Header: w.Header().Clone(),
+				Body:   w.body.Bytes(),
+			}, time.Now().Add(h.options.IdempotencyTTL))
+		}
+		if err != nil {
+			log.Printf("unable to record response to Idempotency-Key %q: %v", key, err)
+		}
+		if p != nil {
+			panic(p)
+		}
+	}()
+	c.Next()
+}
+
+// replayResponse sends a stored response and aborts the handlers that follow.
+func replayResponse(c *gin.Context, response *db.StoredResponse) {
+	for name, values := range response.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Writer.WriteHeader(response.Status)
+	c.Writer.Write(response.Body)
+	c.Abort()
+}
+
+// recordingWriter keeps a copy of the body of a response as it is written.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
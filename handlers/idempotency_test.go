@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/clinstid/schools_api/db"
+)
+
+func TestIdempotent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{options: Options{IdempotencyKeys: db.NewMemoryStore(), IdempotencyTTL: time.Hour, IdempotencyLease: time.Minute}}
+
+	// The handler fails with a server error the first time it is called
+	calls := 0
+	r := gin.New()
+	r.POST("/schools", h.Idempotent, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.JSON(http.StatusInternalServerError, buildErrorResponse(internalErrMsg))
+			return
+		}
+		c.Header("Location", "/schools/12")
+		c.String(http.StatusCreated, "created %d", calls)
+	})
+	serve := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/schools", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		key, body string
+		status    int
+		response  string
+		calls     int
+	}{
+		// Server errors are not kept, so the request can be retried
+		{"key-1", "a", http.StatusInternalServerError, "", 1},
+		{"key-1", "a", http.StatusCreated, "created 2", 2},
+		{"key-1", "a", http.StatusCreated, "created 2", 2},
+		{"key-1", "b", http.StatusUnprocessableEntity, idempotencyKeyReusedErrMsg, 2},
+		{"key-2", "a", http.StatusCreated, "created 3", 3},
+		{"", "a", http.StatusCreated, "created 4", 4},
+		{strings.Repeat("k", maxIdempotencyKeyLength+1), "a", http.StatusBadRequest, idempotencyKeyTooLongErrMsg, 4},
+	}
+	for i, tt := range tests {
+		w := serve(tt.key, tt.body)
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.response) {
+			t.Errorf("request %d = %d %s, want %d %s", i, w.Code, w.Body, tt.status, tt.response)
+		}
+		if calls != tt.calls {
+			t.Errorf("request %d: handler called %d times, want %d", i, calls, tt.calls)
+		}
+	}
+
+	replayed := serve("key-1", "a")
+	if replayed.Header().Get("Location") != "/schools/12" || replayed.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replayed headers = %v, want Location and Idempotent-Replayed", replayed.Header())
+	}
+}
+
+func TestIdempotentPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{options: Options{IdempotencyKeys: db.NewMemoryStore(), IdempotencyTTL: time.Hour, IdempotencyLease: time.Minute}}
+
+	// The handler panics the first time it is called
+	calls := 0
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.POST("/schools", h.Idempotent, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.String(http.StatusCreated, "created %d", calls)
+	})
+
+	// The key is released, so the retry is handled instead of being turned
+	// away as in progress
+	for i, want := range []int{http.StatusInternalServerError, http.StatusCreated} {
+		req := httptest.NewRequest("POST", "/schools", strings.NewReader("a"))
+		req.Header.Set("Idempotency-Key", "key-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("request %d = %d %s, want %d", i, w.Code, w.Body, want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/clinstid/schools_api/resources"
+	"github.com/gin-gonic/gin"
+)
+
+// Media types that school resources can be rendered as
+const (
+	mediaJSON   = "application/json"
+	mediaCSV    = "text/csv"
+	mediaXML    = "application/xml"
+	mediaYAML   = "application/yaml"
+	mediaNDJSON = "application/x-ndjson"
+)
+
+// schoolMediaTypes are the media types offered by GetSchool and ListSchools,
+// in order of preference when a client accepts several equally.
+var schoolMediaTypes = []string{mediaJSON, mediaCSV, mediaXML, mediaYAML, mediaNDJSON}
+
+// mediaAliases maps other names in common use for the offered media types to
+// the names used in responses.
+var mediaAliases = map[string]string{
+	"text/xml":           mediaXML,
+	"application/x-yaml": mediaYAML,
+	"text/yaml":          mediaYAML,
+	"application/jsonl":  mediaNDJSON,
+}
+
+// mediaRange is one element of an Accept header, such as text/* or
+// application/json;q=0.5.
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses the media ranges in an Accept header. Malformed ranges
+// are skipped.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil || strings.Count(mediaType, "/") != 1 {
+			continue
+		}
+		if alias, ok := mediaAliases[mediaType]; ok {
+			mediaType = alias
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// specificity returns how closely r matches mediaType: 3 for an exact match,
+// 2 for a type/* range, 1 for */* and 0 if it does not match at all.
+func (r mediaRange) specificity(mediaType string) int {
+	switch {
+	case r.mediaType == mediaType:
+		return 3
+	case r.mediaType == "*/*":
+		return 1
+	case strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*")):
+		return 2
+	default:
+		return 0
+	}
+}
+
+// negotiate returns the media type in offered that the Accept header prefers,
+// or an empty string if it accepts none of them. Each offer is weighted by
+// the quality of the most specific range that matches it and ties go to the
+// earliest offer, which is also returned when there is no Accept header.
+func negotiate(accept string, offered []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+	ranges := parseAccept(accept)
+
+	var best string
+	var bestQ float64
+	for _, offer := range offered {
+		q, specificity := 0.0, 0
+		for _, r := range ranges {
+			if s := r.specificity(offer); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// negotiateSchools selects the media type of a GetSchool or ListSchools
+// response. If the client accepts none of them a 406 response is sent and an
+// empty string is returned.
+func negotiateSchools(c *gin.Context) string {
+	c.Header("Vary", "Accept")
+	mediaType := negotiate(c.GetHeader("Accept"), schoolMediaTypes)
+	if mediaType == "" {
+		c.JSON(http.StatusNotAcceptable, buildErrorResponse(notAcceptableErrMsg))
+	}
+	return mediaType
+}
+
+// renderSchools renders body as mediaType. CSV and NDJSON have no room for
+// anything but the schools themselves, so for those only schools is
+// rendered, as a header row followed by one row per school or as one JSON
+// object per line.
+func renderSchools(c *gin.Context, mediaType string, body interface{}, schools []resources.School) {
+	switch mediaType {
+	case mediaCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(resources.CSVHeader())
+		for i := range schools {
+			w.Write(schools[i].CSVRecord())
+		}
+		w.Flush()
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	case mediaNDJSON:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for i := range schools {
+			enc.Encode(&schools[i])
+		}
+		c.Data(http.StatusOK, mediaNDJSON, buf.Bytes())
+	case mediaXML:
+		c.XML(http.StatusOK, body)
+	case mediaYAML:
+		c.YAML(http.StatusOK, body)
+	default:
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// setPageHeaders describes a page of a collection in response headers, for
+// media types whose bodies only hold the schools: the total in X-Total-Count
+// and the links in a Link header.
+func setPageHeaders(c *gin.Context, meta resources.Meta, links resources.Links) {
+	c.Header("X-Total-Count", strconv.Itoa(meta.Total))
+	var parts []string
+	for _, link := range []struct{ rel, url string }{
+		{"first", links.First},
+		{"last", links.Last},
+		{"next", links.Next},
+		{"prev", links.Prev},
+	} {
+		if link.url != "" {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, link.url, link.rel))
+		}
+	}
+	if len(parts) > 0 {
+		c.Header("Link", strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", mediaJSON},
+		{"*/*", mediaJSON},
+		{"text/csv", mediaCSV},
+		{"text/*", mediaCSV},
+		{"text/xml", mediaXML},
+		{"application/x-yaml", mediaYAML},
+		{"application/jsonl", mediaNDJSON},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", mediaXML},
+		{"application/json;q=0.5, text/csv", mediaCSV},
+		{"*/*;q=0.1, application/json;q=0", mediaCSV},
+		{"application/yaml; charset=utf-8", mediaYAML},
+		{"text/html", ""},
+		{"application/json;q=0", ""},
+		{"application/json;q=high", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiate(tt.accept, schoolMediaTypes); got != tt.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
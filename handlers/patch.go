@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+
+	"github.com/clinstid/schools_api/resources"
+	"github.com/clinstid/schools_api/validation"
+)
+
+// Media types of the patch documents accepted by PatchSchool
+const (
+	mediaMergePatch = "application/merge-patch+json"
+	mediaJSONPatch  = "application/json-patch+json"
+)
+
+// patchOperations are the operations of a JSON Patch document.
+var patchOperations = map[string]bool{
+	"add":     true,
+	"remove":  true,
+	"replace": true,
+	"move":    true,
+	"copy":    true,
+	"test":    true,
+}
+
+// patchInvalidError is returned by patchSchool for patch documents that are
+// malformed, which is reported with a 400 response.
+type patchInvalidError struct {
+	message string
+}
+
+func (e *patchInvalidError) Error() string {
+	return e.message
+}
+
+// patchFailedError is returned by patchSchool for well formed patch
+// documents that cannot be applied to the school, such as a JSON Patch with a
+// failing test operation, which is reported with a 422 response.
+type patchFailedError struct {
+	message string
+}
+
+func (e *patchFailedError) Error() string {
+	return e.message
+}
+
+// patchSchool applies patch, a document of the media type mediaType, to school
+// and returns the patched school. The result is normalized and checked
+// against the same rules as the body of UpdateSchool, so validation errors
+// are returned as they would be by a call to Bind.
+func patchSchool(school resources.School, mediaType string, patch []byte) (resources.School, error) {
+	doc, err := json.Marshal(school)
+	if err != nil {
+		return resources.School{}, err
+	}
+
+	var patched []byte
+	switch mediaType {
+	case mediaMergePatch:
+		// A merge patch that is not an object replaces the whole
+		// school, which can never result in a valid school.
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+			return resources.School{}, &patchInvalidError{mergePatchInvalidErrMsg}
+		}
+		if patched, err = jsonpatch.MergePatch(doc, patch); err != nil {
+			return resources.School{}, &patchInvalidError{mergePatchInvalidErrMsg}
+		}
+	case mediaJSONPatch:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return resources.School{}, &patchInvalidError{jsonPatchInvalidErrMsg}
+		}
+		for i, op := range ops {
+			if _, err := op.Path(); err != nil || !patchOperations[op.Kind()] {
+				return resources.School{}, &patchInvalidError{fmt.Sprintf("%s: operation %d is not valid", jsonPatchInvalidErrMsg, i)}
+			}
+		}
+		if patched, err = ops.Apply(doc); err != nil {
+			return resources.School{}, &patchFailedError{fmt.Sprintf("%s: %v", patchFailedErrMsg, err)}
+		}
+	default:
+		return resources.School{}, &patchInvalidError{patchTypeErrMsg}
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(patched), []byte("{")) {
+		return resources.School{}, &patchFailedError{patchNotObjectErrMsg}
+	}
+	var result resources.School
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return resources.School{}, err
+	}
+	if err := validation.Struct(&result); err != nil {
+		return resources.School{}, err
+	}
+	return result, nil
+}
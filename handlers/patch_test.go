@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/clinstid/schools_api/resources"
+)
+
+func TestPatchSchool(t *testing.T) {
+	school := resources.School{ID: 12, Name: "Auburn University", City: "Auburn", Type: "public"}
+
+	tests := []struct {
+		mediaType string
+		patch     string
+		want      resources.School
+		err       string
+	}{
+		{mediaMergePatch, `{"city": "Montgomery", "type": null}`, resources.School{ID: 12, Name: "Auburn University", City: "Montgomery"}, ""},
+		{mediaMergePatch, `{"name": "  Auburn  ", "id": 13}`, resources.School{ID: 13, Name: "Auburn", City: "Auburn", Type: "public"}, ""},
+		{mediaMergePatch, `{"name": null}`, resources.School{}, `Field "name" is required`},
+		{mediaMergePatch, `{"type": "charter"}`, resources.School{}, `Field "type" must be one of public, private, for-profit`},
+		{mediaMergePatch, `{"city": 42}`, resources.School{}, `Field "city" must be a string`},
+		{mediaMergePatch, `["Auburn"]`, resources.School{}, mergePatchInvalidErrMsg},
+		{mediaMergePatch, `null`, resources.School{}, mergePatchInvalidErrMsg},
+		{mediaMergePatch, `{"name": `, resources.School{}, mergePatchInvalidErrMsg},
+		{mediaJSONPatch, `[{"op": "test", "path": "/city", "value": "Auburn"}, {"op": "add", "path": "/level", "value": "4-year"}, {"op": "remove", "path": "/type"}]`, resources.School{ID: 12, Name: "Auburn University", City: "Auburn", Level: "4-year"}, ""},
+		{mediaJSONPatch, `[{"op": "copy", "from": "/city", "path": "/state"}]`, resources.School{ID: 12, Name: "Auburn University", City: "Auburn", State: "Auburn", Type: "public"}, ""},
+		{mediaJSONPatch, `[{"op": "test", "path": "/city", "value": "Montgomery"}]`, resources.School{}, patchFailedErrMsg + ": testing value /city failed: test failed"},
+		{mediaJSONPatch, `[{"op": "replace", "path": "", "value": ["Auburn"]}]`, resources.School{}, patchNotObjectErrMsg},
+		{mediaJSONPatch, `[{"op": "rename", "path": "/city"}]`, resources.School{}, jsonPatchInvalidErrMsg + ": operation 0 is not valid"},
+		{mediaJSONPatch, `{"op": "remove", "path": "/city"}`, resources.School{}, jsonPatchInvalidErrMsg},
+	}
+	for _, tt := range tests {
+		got, err := patchSchool(school, tt.mediaType, []byte(tt.patch))
+		if tt.err != "" {
+			// Errors are reported as they are in responses
+			if err == nil || buildBindErrorResponse(err).Message != tt.err {
+				t.Errorf("patchSchool(%s) error = %v, want %q", tt.patch, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("patchSchool(%s) error: %v", tt.patch, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("patchSchool(%s) = %+v, want %+v", tt.patch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Package importer loads schools in bulk from CSV and JSON Lines files. Every
+// row is validated with the same rules as the API before any change is made,
+// and the rows are then applied in a single transaction so that an import
+// either succeeds completely or has no effect.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/resources"
+	"github.com/clinstid/schools_api/validation"
+)
+
+// Formats of the files that can be imported
+const (
+	// FormatCSV is a CSV file with a header row naming the columns, which
+	// have the same names as the fields of a school in the API.
+	FormatCSV = "csv"
+
+	// FormatJSONL is a JSON Lines file with one school object per line.
+	FormatJSONL = "jsonl"
+)
+
+// maxLineSize is the longest line accepted in a JSON Lines file.
+const maxLineSize = 1 << 20
+
+// idColumn is the CSV column holding the id of a school in an export. It is
+// ignored so that exports can be imported, since ids are assigned by the
+// store.
+const idColumn = "id"
+
+// Options changes how rows are applied.
+type Options struct {
+	// Upsert updates the active school with the same external id as a row
+	// instead of adding a new school. Rows without an external id are
+	// always added.
+	Upsert bool
+}
+
+// Result counts the schools changed by an import.
+type Result struct {
+	Created int
+	Updated int
+}
+
+// RowError is a problem with one row of an import.
+type RowError struct {
+	// Line is the line of the file the row starts on, counting from 1.
+	Line int
+
+	// Field is the name of the field that is invalid, if the problem is
+	// with a single field.
+	Field string
+
+	Message string
+}
+
+// InvalidRowsError is returned by Import when some rows are invalid. None of
+// the rows are applied.
+type InvalidRowsError struct {
+	Errors []RowError
+}
+
+func (e *InvalidRowsError) Error() string {
+	first := e.Errors[0]
+	return fmt.Sprintf("%d invalid rows, the first on line %d: %s", len(e.Errors), first.Line, first.Message)
+}
+
+// row is a school read from the file along with where it was found.
+type row struct {
+	line   int
+	school resources.School
+}
+
+// Import reads the schools in r, which is in the specified format, and adds
+// them to store, or updates existing schools when opts.Upsert is set. If any
+// row is invalid nothing is changed and an *InvalidRowsError listing every
+// problem is returned.
+func Import(ctx context.Context, store db.SchoolStore, r io.Reader, format string, opts Options) (Result, error) {
+	var rows []row
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(r)
+	case FormatJSONL:
+		rows, err = readJSONL(r)
+	default:
+		return Result{}, fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	rowErrs := validateRows(rows, opts)
+	if len(rowErrs) > 0 {
+		return Result{}, &InvalidRowsError{Errors: rowErrs}
+	}
+
+	var result Result
+	err = store.InTransaction(ctx, func(tx db.SchoolStore) error {
+		result = Result{}
+		for _, row := range rows {
+			school := row.school.StoreSchool(0)
+			if opts.Upsert && school.ExternalID != "" {
+				existing, err := tx.GetSchools(ctx, db.ListOptions{Limit: 2, ExternalID: school.ExternalID})
+				if err != nil {
+					return err
+				}
+				switch len(existing.Schools) {
+				case 0:
+				case 1:
+					school.ID = existing.Schools[0].ID
+					_, err := tx.UpdateSchool(ctx, school)
+					if conflict, ok := err.(*db.ConflictError); ok {
+						rowErrs = append(rowErrs, conflictError(row.line, conflict))
+						continue
+					} else if err != nil {
+						return err
+					}
+					result.Updated++
+					continue
+				default:
+					rowErrs = append(rowErrs, RowError{
+						Line:    row.line,
+						Field:   "external_id",
+						Message: fmt.Sprintf("external_id %q matches more than one school", school.ExternalID),
+					})
+					continue
+				}
+			}
+
+			_, err := tx.AddSchool(ctx, school)
+			if conflict, ok := err.(*db.ConflictError); ok {
+				rowErrs = append(rowErrs, conflictError(row.line, conflict))
+				continue
+			} else if err != nil {
+				return err
+			}
+			result.Created++
+		}
+
+		if len(rowErrs) > 0 {
+			return &InvalidRowsError{Errors: rowErrs}
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// conflictError describes a row whose name is already taken by an existing
+// school.
+func conflictError(line int, err *db.ConflictError) RowError {
+	return RowError{Line: line, Field: "name", Message: err.Error()}
+}
+
+// validateRows checks every row against the validation rules for schools,
+// that no two rows have the same normalized name and, when upserting, that no
+// two rows have the same external id.
+func validateRows(rows []row, opts Options) []RowError {
+	var rowErrs []RowError
+	names := make(map[string]int)
+	externalIDs := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+		if err := validation.Struct(&row.school); err != nil {
+			rowErrs = append(rowErrs, RowError{
+				Line:    row.line,
+				Field:   validation.Field(err),
+				Message: validation.Message(err),
+			})
+			continue
+		}
+
+		name := db.NormalizeName(row.school.Name)
+		if line, ok := names[name]; ok {
+			rowErrs = append(rowErrs, RowError{
+				Line:    row.line,
+				Field:   "name",
+				Message: fmt.Sprintf("name %q is also used on line %d", row.school.Name, line),
+			})
+			continue
+		}
+		names[name] = row.line
+
+		id := row.school.ExternalID
+		if !opts.Upsert || id == "" {
+			continue
+		}
+		if line, ok := externalIDs[id]; ok {
+			rowErrs = append(rowErrs, RowError{
+				Line:    row.line,
+				Field:   "external_id",
+				Message: fmt.Sprintf("external_id %q is also used on line %d", id, line),
+			})
+			continue
+		}
+		externalIDs[id] = row.line
+	}
+	return rowErrs
+}
+
+// readCSV reads the rows of a CSV file. Problems with the header row are
+// reported as an *InvalidRowsError since no row can be read without it.
+func readCSV(r io.Reader) ([]row, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, &InvalidRowsError{Errors: []RowError{{Line: 1, Message: "missing header row"}}}
+	} else if err != nil {
+		return nil, csvError(err)
+	}
+
+	// Spreadsheets often start CSV files with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	var headerErrs []RowError
+	columns := (&resources.School{}).CSVFields()
+	seen := make(map[string]bool)
+	for _, column := range header {
+		switch {
+		case column == idColumn:
+		case columns[column] == nil:
+			headerErrs = append(headerErrs, RowError{Line: 1, Message: fmt.Sprintf("unknown column %q", column)})
+		case seen[column]:
+			headerErrs = append(headerErrs, RowError{Line: 1, Message: fmt.Sprintf("duplicate column %q", column)})
+		}
+		seen[column] = true
+	}
+	if !seen["name"] {
+		headerErrs = append(headerErrs, RowError{Line: 1, Message: `missing column "name"`})
+	}
+	if len(headerErrs) > 0 {
+		return nil, &InvalidRowsError{Errors: headerErrs}
+	}
+
+	var rows []row
+	var rowErrs []RowError
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Records with the wrong number of fields can be skipped, but
+			// the reader cannot recover from malformed quoting.
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+				rowErrs = append(rowErrs, RowError{Line: parseErr.StartLine, Message: fmt.Sprintf("expected %d fields, got %d", len(header), len(record))})
+				continue
+			}
+			return nil, csvError(err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		next := row{line: line}
+		fields := next.school.CSVFields()
+		for i, value := range record {
+			if header[i] != idColumn {
+				*fields[header[i]] = value
+			}
+		}
+		rows = append(rows, next)
+	}
+	if len(rowErrs) > 0 {
+		return nil, &InvalidRowsError{Errors: rowErrs}
+	}
+	return rows, nil
+}
+
+// csvError reports malformed CSV as an *InvalidRowsError for the line it was
+// found on. Other errors, such as errors reading the file, are returned
+// unchanged.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &InvalidRowsError{Errors: []RowError{{Line: parseErr.Line, Message: parseErr.Err.Error()}}}
+	}
+	return err
+}
+
+// readJSONL reads the rows of a JSON Lines file. Blank lines are skipped.
+func readJSONL(r io.Reader) ([]row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+
+	var rows []row
+	var rowErrs []RowError
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		next := row{line: line}
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&next.school); err != nil {
+			rowErrs = append(rowErrs, jsonError(line, err))
+			continue
+		}
+		if dec.More() {
+			rowErrs = append(rowErrs, RowError{Line: line, Message: "expected one JSON object per line"})
+			continue
+		}
+		rows = append(rows, next)
+	}
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		tooLong := RowError{Line: line + 1, Message: fmt.Sprintf("line is longer than %d bytes", maxLineSize)}
+		return nil, &InvalidRowsError{Errors: append(rowErrs, tooLong)}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(rowErrs) > 0 {
+		return nil, &InvalidRowsError{Errors: rowErrs}
+	}
+	return rows, nil
+}
+
+// jsonError describes a line of a JSON Lines file that could not be decoded.
+func jsonError(line int, err error) RowError {
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return RowError{
+			Line:    line,
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("Field %q must be a %s", typeErr.Field, typeErr.Type),
+		}
+	}
+	return RowError{Line: line, Message: "invalid JSON: " + strings.TrimPrefix(err.Error(), "json: ")}
+}
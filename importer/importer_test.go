@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clinstid/schools_api/db"
+)
+
+func TestImportCSV(t *testing.T) {
+	store := db.NewMemoryStore()
+	ctx := context.Background()
+	input := "\ufeffname,city,state,type,external_id\n" +
+		"Import Test College,Auburn,AL,public,900001\n" +
+		"\"Import Test Institute, Inc\",,,for-profit,\n"
+
+	result, err := Import(ctx, store, strings.NewReader(input), FormatCSV, Options{})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result != (Result{Created: 2}) {
+		t.Errorf("Import returned %+v, want 2 created", result)
+	}
+
+	page, err := store.GetSchools(ctx, db.ListOptions{Limit: 10, Filter: db.NameFilter{Prefix: "Import Test"}})
+	if err != nil {
+		t.Fatalf("GetSchools: %v", err)
+	}
+	if page.Total != 2 {
+		t.Fatalf("found %d imported schools, want 2", page.Total)
+	}
+	got := page.Schools[0]
+	want := db.School{ID: got.ID, Name: "Import Test College", City: "Auburn", State: "AL", Type: db.TypePublic, ExternalID: "900001", Version: 1, UpdatedAt: got.UpdatedAt}
+	if got != want {
+		t.Errorf("imported %+v, want %+v", got, want)
+	}
+	if page.Schools[1].Name != "Import Test Institute, Inc" {
+		t.Errorf("imported %q, want the quoted name", page.Schools[1].Name)
+	}
+}
+
+func TestImportJSONLUpsert(t *testing.T) {
+	store := db.NewMemoryStore()
+	ctx := context.Background()
+	input := `{"name": "Upsert Test College", "external_id": "900002", "level": "2-year"}
+
+{"name": "Upsert Test University"}
+`
+	if _, err := Import(ctx, store, strings.NewReader(input), FormatJSONL, Options{Upsert: true}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	refresh := `{"name": "Upsert Test College", "external_id": "900002", "level": "4-year"}` + "\n"
+	result, err := Import(ctx, store, strings.NewReader(refresh), FormatJSONL, Options{Upsert: true})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result != (Result{Updated: 1}) {
+		t.Errorf("Import returned %+v, want 1 updated", result)
+	}
+
+	page, err := store.GetSchools(ctx, db.ListOptions{Limit: 10, ExternalID: "900002"})
+	if err != nil {
+		t.Fatalf("GetSchools: %v", err)
+	}
+	if page.Total != 1 || page.Schools[0].Level != db.LevelFourYear {
+		t.Errorf("schools with external id 900002 are %+v, want one 4-year school", page.Schools)
+	}
+}
+
+func TestImportInvalidRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		opts   Options
+		input  string
+		want   []RowError
+	}{
+		{
+			name:   "unknown column",
+			format: FormatCSV,
+			input:  "name,color\nA,blue\n",
+			want:   []RowError{{Line: 1, Message: `unknown column "color"`}},
+		},
+		{
+			name:   "missing name column",
+			format: FormatCSV,
+			input:  "city\nAuburn\n",
+			want:   []RowError{{Line: 1, Message: `missing column "name"`}},
+		},
+		{
+			name:   "invalid fields",
+			format: FormatCSV,
+			input:  "name,type\nGood College,public\n,public\nBad College,charter\nShort\n",
+			want: []RowError{
+				{Line: 5, Message: "expected 2 fields, got 1"},
+			},
+		},
+		{
+			name:   "validation",
+			format: FormatCSV,
+			input:  "name,type\nGood College,public\n,public\nBad College,charter\n",
+			want: []RowError{
+				{Line: 3, Field: "name", Message: `Field "name" is required`},
+				{Line: 4, Field: "type", Message: `Field "type" must be one of public, private, for-profit`},
+			},
+		},
+		{
+			name:   "json",
+			format: FormatJSONL,
+			input:  "{\"name\": 42}\n{\"name\": \"A\", \"colour\": \"blue\"}\nnot json\n",
+			want: []RowError{
+				{Line: 1, Field: "name", Message: `Field "name" must be a string`},
+				{Line: 2, Message: `invalid JSON: unknown field "colour"`},
+				{Line: 3, Message: "invalid JSON: invalid character 'o' in literal null (expecting 'u')"},
+			},
+		},
+		{
+			name:   "duplicate name",
+			format: FormatCSV,
+			input:  "name\nDuplicate College\nduplicate college.\n",
+			want:   []RowError{{Line: 3, Field: "name", Message: `name "duplicate college." is also used on line 2`}},
+		},
+		{
+			name:   "existing name",
+			format: FormatJSONL,
+			input:  "{\"name\": \"New College\"}\n{\"name\": \"Glendale Community College\"}\n",
+			want:   []RowError{{Line: 2, Field: "name", Message: `School with id 103 already has the name "Glendale Community College"`}},
+		},
+		{
+			name:   "duplicate external id",
+			format: FormatJSONL,
+			opts:   Options{Upsert: true},
+			input:  "{\"name\": \"A\", \"external_id\": \"1\"}\n{\"name\": \"B\", \"external_id\": \"1\"}\n",
+			want:   []RowError{{Line: 2, Field: "external_id", Message: `external_id "1" is also used on line 1`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := db.NewMemoryStore()
+			ctx := context.Background()
+			before, _ := store.GetSchools(ctx, db.ListOptions{Limit: 1})
+
+			_, err := Import(ctx, store, strings.NewReader(tt.input), tt.format, tt.opts)
+			rowsErr, ok := err.(*InvalidRowsError)
+			if !ok {
+				t.Fatalf("Import returned %v, want an *InvalidRowsError", err)
+			}
+			if !reflect.DeepEqual(rowsErr.Errors, tt.want) {
+				t.Errorf("Import reported %+v, want %+v", rowsErr.Errors, tt.want)
+			}
+
+			after, _ := store.GetSchools(ctx, db.ListOptions{Limit: 1})
+			if after.Total != before.Total {
+				t.Errorf("Import changed the number of schools from %d to %d", before.Total, after.Total)
+			}
+		})
+	}
+}
@@ -1,11 +1,229 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clinstid/schools_api/config"
+	"github.com/clinstid/schools_api/cursor"
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/handlers"
+	"github.com/clinstid/schools_api/importer"
+	"github.com/clinstid/schools_api/openapi"
 	"github.com/clinstid/schools_api/routes"
+	"github.com/clinstid/schools_api/search"
 )
 
+const usage = `usage: schools_api [command]
+
+Commands:
+  serve    Run the HTTP API (default)
+  migrate  Apply pending database migrations and exit
+  import   Import schools from a CSV or JSON Lines file and exit, run
+           "schools_api import -h" for its options
+`
+
+// openStore returns the db.SchoolStore selected by the configuration.
+func openStore(cfg *config.Config) (db.SchoolStore, error) {
+	switch cfg.Store {
+	case config.StoreSQLite:
+		return db.NewSQLiteStore(cfg.SQLitePath)
+	case config.StorePostgres:
+		return db.NewPostgresStore(cfg.PostgresDSN)
+	default:
+		return db.NewMemoryStore(), nil
+	}
+}
+
+// runMigrations applies pending migrations to store if it has a schema to
+// manage.
+func runMigrations(ctx context.Context, store db.SchoolStore) error {
+	m, ok := store.(db.Migrator)
+	if !ok {
+		return nil
+	}
+
+	applied, err := m.Migrate(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("applied %d migrations", applied)
+	return nil
+}
+
+// checkMigrations returns an error if store has migrations that have not been
+// applied.
+func checkMigrations(ctx context.Context, store db.SchoolStore) error {
+	m, ok := store.(db.Migrator)
+	if !ok {
+		return nil
+	}
+
+	pending, err := m.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations are pending, run the migrate command first", pending)
+	}
+	return nil
+}
+
+// prepareStore applies or checks for pending migrations before store is used,
+// depending on the configuration.
+func prepareStore(ctx context.Context, cfg *config.Config, store db.SchoolStore) error {
+	if cfg.AutoMigrate {
+		return runMigrations(ctx, store)
+	}
+	return checkMigrations(ctx, store)
+}
+
+// runImport runs the import command with the command line arguments in args.
+func runImport(ctx context.Context, cfg *config.Config, store db.SchoolStore, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: schools_api import [-upsert] [-format csv|jsonl] FILE")
+		flags.PrintDefaults()
+	}
+	upsert := flags.Bool("upsert", false, "update the schools with the same external_id as a row instead of adding new ones")
+	format := flags.String("format", "", "format of FILE, by default guessed from its extension")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	path := flags.Arg(0)
+
+	if cfg.Store == config.StoreMemory {
+		return fmt.Errorf("the %s store does not keep imported schools, set SCHOOLS_STORE", config.StoreMemory)
+	}
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = importer.FormatCSV
+		case ".jsonl", ".ndjson":
+			*format = importer.FormatJSONL
+		default:
+			return fmt.Errorf("cannot tell the format of %s from its extension, use -format", path)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	result, err := importer.Import(ctx, store, f, *format, importer.Options{Upsert: *upsert})
+	if rowsErr, ok := err.(*importer.InvalidRowsError); ok {
+		for _, rowErr := range rowsErr.Errors {
+			log.Printf("%s:%d: %s", path, rowErr.Line, rowErr.Message)
+		}
+		return fmt.Errorf("%d invalid rows, no schools were changed", len(rowsErr.Errors))
+	} else if err != nil {
+		return err
+	}
+	log.Printf("imported %s: %d schools created, %d updated", path, result.Created, result.Updated)
+	return nil
+}
+
+// cursorKey returns the key used to sign pagination cursors, generating a
+// random one if none is configured.
+func cursorKey(cfg *config.Config) []byte {
+	if cfg.CursorSecret != "" {
+		return []byte(cfg.CursorSecret)
+	}
+
+	log.Print("no cursor secret configured, pagination cursors will not survive a restart")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("unable to generate cursor key: %v", err)
+	}
+	return key
+}
+
 func main() {
-	r := routes.SetupRouter()
-	// Listen and Server in 0.0.0.0:8080
-	r.Run(":8080")
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	if command != "serve" && command != "migrate" && command != "import" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatalf("unable to open %s store: %v", cfg.Store, err)
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "migrate":
+		if err := runMigrations(ctx, store); err != nil {
+			log.Fatalf("unable to migrate %s store: %v", cfg.Store, err)
+		}
+
+	case "import":
+		if err := prepareStore(ctx, cfg, store); err != nil {
+			log.Fatalf("unable to prepare %s store: %v", cfg.Store, err)
+		}
+		if err := runImport(ctx, cfg, store, os.Args[2:]); err != nil {
+			log.Fatalf("unable to import: %v", err)
+		}
+
+	case "serve":
+		if err := prepareStore(ctx, cfg, store); err != nil {
+			log.Fatalf("unable to prepare %s store: %v", cfg.Store, err)
+		}
+
+		if cfg.TombstoneRetention > 0 {
+			go db.PurgeDeletedSchools(ctx, store, cfg.TombstoneRetention, cfg.PurgeInterval)
+		}
+
+		handlerOptions := handlers.Options{RequireIfMatch: cfg.RequireIfMatch}
+		if keys, ok := store.(db.IdempotencyStore); ok && cfg.IdempotencyTTL > 0 {
+			handlerOptions.IdempotencyKeys = keys
+			handlerOptions.IdempotencyTTL = cfg.IdempotencyTTL
+			handlerOptions.IdempotencyLease = cfg.IdempotencyLease
+			go db.PurgeExpiredRequests(ctx, keys, cfg.PurgeInterval)
+		}
+
+		// Keep the search index up to date by making every change through
+		// the IndexedStore.
+		index := search.NewIndex()
+		if err := index.Load(ctx, store); err != nil {
+			log.Fatalf("unable to build search index: %v", err)
+		}
+		store = search.NewIndexedStore(store, index)
+
+		if cfg.ValidateResponses {
+			log.Print("validating responses against the API specification")
+		}
+		if cfg.RequireIfMatch {
+			log.Print("requiring If-Match on changes to schools")
+		}
+		options := routes.Options{
+			Handlers: handlerOptions,
+			OpenAPI:  openapi.Options{ValidateResponses: cfg.ValidateResponses},
+		}
+		r, err := routes.SetupRouter(store, index, cursor.NewCodec(cursorKey(cfg)), options)
+		if err != nil {
+			log.Fatalf("unable to set up routes: %v", err)
+		}
+		// Listen and Serve on the configured address, 0.0.0.0:8080 by default
+		r.Run(cfg.Addr)
+	}
 }
@@ -0,0 +1,371 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// API describes the parts of the specification that cannot be derived from
+// the routes and the Go types they use, such as summaries and descriptions.
+type API struct {
+	Info *openapi3.Info
+
+	// Operations describes the handler of each route, by the name of the
+	// handler function, which is also used as the operation id.
+	Operations map[string]Operation
+
+	// Schemas describes the Go types used in requests and responses, by the
+	// name of the type.
+	Schemas map[string]Schema
+}
+
+// Operation describes the handler of a route.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Parameters lists the path, query and header parameters. Every
+	// parameter in the path of the route must be listed.
+	Parameters []*openapi3.Parameter
+
+	Body      *Body
+	Responses []Response
+}
+
+// Body describes the request body of an operation.
+type Body struct {
+	Description string
+	Required    bool
+
+	// Content maps each accepted media type to a value of the Go type the
+	// body is decoded to, or to an *openapi3.Schema for bodies that are not
+	// decoded to a Go type.
+	Content map[string]interface{}
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Status      int
+	Description string
+
+	// Headers maps the headers of the response to their descriptions.
+	Headers map[string]string
+
+	// Content maps each media type the response can be sent as to a value
+	// of the Go type that is encoded as the body, or to an *openapi3.Schema
+	// for bodies that are not encoded from a Go type.
+	Content map[string]interface{}
+}
+
+// Schema describes a Go type. The properties of the schema and the rules
+// they must follow are taken from the json and binding tags of the type.
+type Schema struct {
+	Description string
+
+	// Fields describes the fields of a struct, by their JSON names.
+	Fields map[string]string
+
+	// ReadOnly lists the JSON names of fields that are set by the server and
+	// ignored in requests.
+	ReadOnly []string
+}
+
+// Generate returns the specification of the API served by routes. Each route
+// is described by the operation of its handler in api. It returns an error if
+// a route has no operation or the specification is not valid.
+func Generate(api API, routes gin.RoutesInfo) (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI:    "3.0.3",
+		Info:       api.Info,
+		Paths:      openapi3.NewPaths(),
+		Components: &openapi3.Components{Schemas: make(openapi3.Schemas)},
+	}
+	g := &generator{schemas: api.Schemas, components: doc.Components.Schemas}
+
+	var missing []string
+	for _, route := range routes {
+		id := handlerName(route.Handler)
+		op, ok := api.Operations[id]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s %s (%s)", route.Method, route.Path, id))
+			continue
+		}
+
+		if err := checkPathParams(route.Path, op); err != nil {
+			return nil, fmt.Errorf("invalid OpenAPI spec: %s %s: %v", route.Method, route.Path, err)
+		}
+
+		path := specPath(route.Path)
+		item := doc.Paths.Value(path)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths.Set(path, item)
+		}
+		item.SetOperation(route.Method, g.operation(id, op))
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("routes missing from the OpenAPI spec: %s", strings.Join(missing, ", "))
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %v", err)
+	}
+	return doc, nil
+}
+
+// Handler returns a gin handler that serves doc as JSON.
+func Handler(doc *openapi3.T) (gin.HandlerFunc, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+	}, nil
+}
+
+// handlerName returns the name of a handler function from the name gin
+// reports for it, such as
+// "github.com/clinstid/schools_api/handlers.(*Handler).ListSchools-fm".
+func handlerName(name string) string {
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// specPath converts the path of a gin route, such as /schools/:schoolID, to
+// an OpenAPI path, such as /schools/{schoolID}.
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// checkPathParams returns an error unless op describes exactly the
+// parameters in path, the path of a gin route.
+func checkPathParams(path string, op Operation) error {
+	described := make(map[string]bool)
+	for _, param := range op.Parameters {
+		if param.In == openapi3.ParameterInPath {
+			described[param.Name] = true
+		}
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		if !described[segment[1:]] {
+			return fmt.Errorf("path parameter %s is not described", segment[1:])
+		}
+		delete(described, segment[1:])
+	}
+	for name := range described {
+		return fmt.Errorf("path parameter %s is not in the path", name)
+	}
+	return nil
+}
+
+// generator builds the parts of a specification, adding a component schema
+// for each struct type it comes across.
+type generator struct {
+	schemas    map[string]Schema
+	components openapi3.Schemas
+}
+
+func (g *generator) operation(id string, op Operation) *openapi3.Operation {
+	operation := &openapi3.Operation{
+		OperationID: id,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   openapi3.NewResponses(),
+	}
+	for _, param := range op.Parameters {
+		operation.Parameters = append(operation.Parameters, &openapi3.ParameterRef{Value: param})
+	}
+	if op.Body != nil {
+		operation.RequestBody = &openapi3.RequestBodyRef{Value: &openapi3.RequestBody{
+			Description: op.Body.Description,
+			Required:    op.Body.Required,
+			Content:     g.content(op.Body.Content),
+		}}
+	}
+
+	for _, resp := range op.Responses {
+		description := resp.Description
+		response := &openapi3.Response{Description: &description}
+		if len(resp.Content) > 0 {
+			response.Content = g.content(resp.Content)
+		}
+		for name, desc := range resp.Headers {
+			if response.Headers == nil {
+				response.Headers = make(openapi3.Headers)
+			}
+			response.Headers[name] = &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+				Description: desc,
+				Schema:      openapi3.NewStringSchema().NewRef(),
+			}}}
+		}
+		operation.Responses.Set(strconv.Itoa(resp.Status), &openapi3.ResponseRef{Value: response})
+	}
+	return operation
+}
+
+// content describes the body of each media type in content.
+func (g *generator) content(content map[string]interface{}) openapi3.Content {
+	c := make(openapi3.Content)
+	for mediaType, body := range content {
+		if schema, ok := body.(*openapi3.Schema); ok {
+			c[mediaType] = &openapi3.MediaType{Schema: schema.NewRef()}
+			continue
+		}
+		c[mediaType] = &openapi3.MediaType{Schema: g.schemaRef(reflect.TypeOf(body))}
+	}
+	return c
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef returns the schema of values of t. Named structs are added to the
+// component schemas and referred to by name.
+func (g *generator) schemaRef(t reflect.Type) *openapi3.SchemaRef {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return openapi3.NewDateTimeSchema().NewRef()
+	case t.Kind() == reflect.Struct && t.Name() == "":
+		schema := openapi3.NewObjectSchema()
+		g.fillStruct(schema, t)
+		return schema.NewRef()
+	case t.Kind() == reflect.Struct:
+		ref := "#/components/schemas/" + t.Name()
+		if existing, ok := g.components[t.Name()]; ok {
+			return openapi3.NewSchemaRef(ref, existing.Value)
+		}
+		schema := openapi3.NewObjectSchema()
+		g.components[t.Name()] = schema.NewRef()
+		g.fillStruct(schema, t)
+		return openapi3.NewSchemaRef(ref, schema)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return openapi3.NewStringSchema().NewRef()
+	case reflect.Bool:
+		return openapi3.NewBoolSchema().NewRef()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return openapi3.NewIntegerSchema().NewRef()
+	case reflect.Float32, reflect.Float64:
+		return openapi3.NewFloat64Schema().NewRef()
+	case reflect.Slice, reflect.Array:
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{
+			Type:  &openapi3.Types{openapi3.TypeArray},
+			Items: g.schemaRef(t.Elem()),
+		}}
+	default:
+		return openapi3.NewObjectSchema().NewRef()
+	}
+}
+
+// fillStruct adds the fields of the struct type t to schema.
+func (g *generator) fillStruct(schema *openapi3.Schema, t reflect.Type) {
+	doc := g.schemas[t.Name()]
+	schema.Description = doc.Description
+	readOnly := make(map[string]bool)
+	for _, name := range doc.ReadOnly {
+		readOnly[name] = true
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := g.schemaRef(field.Type)
+		if prop.Ref == "" {
+			// Only schemas that are not references can have their own
+			// description and rules.
+			prop.Value.Description = doc.Fields[name]
+			prop.Value.ReadOnly = readOnly[name]
+			if applyBinding(prop.Value, field.Tag.Get("binding")) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+// applyBinding adds the rules in a binding tag, which are checked by
+// validation.Struct, to schema. It reports whether the field is required.
+func applyBinding(schema *openapi3.Schema, tag string) bool {
+	var required bool
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+			if schema.Type.Is(openapi3.TypeString) {
+				schema.MinLength = 1
+			}
+		case "min":
+			n, err := strconv.ParseInt(param, 10, 64)
+			switch {
+			case err != nil:
+			case schema.Type.Is(openapi3.TypeString):
+				schema.WithMinLength(n)
+			case schema.Type.Is(openapi3.TypeArray):
+				schema.WithMinItems(n)
+			default:
+				schema.WithMin(float64(n))
+			}
+		case "max":
+			n, err := strconv.ParseInt(param, 10, 64)
+			switch {
+			case err != nil:
+			case schema.Type.Is(openapi3.TypeString):
+				schema.WithMaxLength(n)
+			case schema.Type.Is(openapi3.TypeArray):
+				schema.WithMaxItems(n)
+			default:
+				schema.WithMax(float64(n))
+			}
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, value)
+			}
+		case "iso3166_1_alpha2":
+			schema.Pattern = "^[A-Z]{2}$"
+		case "http_url":
+			schema.Format = "uri"
+		}
+	}
+	return required
+}
@@ -0,0 +1,102 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGenerate(t *testing.T) {
+	h := &testHandler{status: http.StatusNoContent}
+	r := gin.New()
+	r.GET("/schools", h.ListSchools)
+	r.POST("/schools", h.AddSchool)
+	r.GET("/schools/:schoolID", h.GetSchool)
+
+	doc, err := Generate(testAPI, r.Routes())
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+
+	item := doc.Paths.Value("/schools/{schoolID}")
+	if item == nil || item.Get == nil || item.Get.OperationID != "GetSchool" {
+		t.Fatalf("GET /schools/{schoolID} is not the GetSchool operation: %+v", item)
+	}
+	if doc.Paths.Value("/schools/search") != nil {
+		t.Errorf("/schools/search has no route but is in the spec")
+	}
+
+	school := doc.Components.Schemas["testSchool"]
+	if school == nil {
+		t.Fatal("testSchool is not a component schema")
+	}
+	if got := school.Value.Required; len(got) != 1 || got[0] != "name" {
+		t.Errorf("required = %q, want [name]", got)
+	}
+	name := school.Value.Properties["name"].Value
+	if name.MinLength != 1 || name.MaxLength == nil || *name.MaxLength != 255 || name.Description != "The name of the school." {
+		t.Errorf("name = %+v, want a description and 1 to 255 characters", name)
+	}
+	if got := school.Value.Properties["country"].Value.Pattern; got != "^[A-Z]{2}$" {
+		t.Errorf("country pattern = %q", got)
+	}
+	if got := school.Value.Properties["type"].Value.Enum; len(got) != 3 || got[2] != "for-profit" {
+		t.Errorf("type enum = %v", got)
+	}
+	if got := school.Value.Properties["website"].Value.Format; got != "uri" {
+		t.Errorf("website format = %q", got)
+	}
+	if got := school.Value.Properties["aliases"].Value; got.MinItems != 1 || got.MaxItems == nil || *got.MaxItems != 3 {
+		t.Errorf("aliases = %+v, want 1 to 3 items", got)
+	}
+	if !school.Value.Properties["id"].Value.ReadOnly {
+		t.Errorf("id is not read only")
+	}
+
+	serveSpec, err := Handler(doc)
+	if err != nil {
+		t.Fatalf("Handler() error: %v", err)
+	}
+	r.GET("/openapi.json", serveSpec)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	var served struct {
+		Paths map[string]interface{}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil {
+		t.Fatalf("invalid /openapi.json %s: %v", w.Body, err)
+	}
+	if _, ok := served.Paths["/schools/{schoolID}"]; !ok {
+		t.Errorf("/openapi.json is missing /schools/{schoolID}: %s", w.Body)
+	}
+}
+
+func TestGenerateMissingOperation(t *testing.T) {
+	h := &testHandler{status: http.StatusNoContent}
+	r := gin.New()
+	r.GET("/schools", h.ListSchools)
+	r.GET("/other", h.Other)
+	r.PATCH("/schools/:schoolID", h.Other)
+
+	_, err := Generate(testAPI, r.Routes())
+	want := "routes missing from the OpenAPI spec: GET /other (Other), PATCH /schools/:schoolID (Other)"
+	if err == nil || err.Error() != want {
+		t.Errorf("Generate() error = %v, want %q", err, want)
+	}
+}
+
+func TestGenerateInvalidSpec(t *testing.T) {
+	h := &testHandler{status: http.StatusNoContent}
+	r := gin.New()
+	// The GetSchool operation describes schoolID, not id.
+	r.GET("/schools/:id", h.GetSchool)
+
+	_, err := Generate(testAPI, r.Routes())
+	if err == nil || !strings.Contains(err.Error(), "invalid OpenAPI spec") {
+		t.Errorf("Generate() error = %v, want an invalid spec", err)
+	}
+}
@@ -0,0 +1,387 @@
+// Package openapi checks requests to the schools API, and optionally its
+// responses, against the OpenAPI specification of the API.
+//
+// Requests that do not match the specification are rejected with a 400
+// response before they reach a handler. The response describes the first
+// problem in message and lists every problem in errors, in the same way as
+// the handlers describe invalid schools:
+//
+//	{
+//	  "message": "limit query parameter must be a number",
+//	  "errors": [
+//	    {
+//	      "field": "limit",
+//	      "message": "limit query parameter must be a number"
+//	    }
+//	  ]
+//	}
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/gin-gonic/gin"
+
+	"github.com/clinstid/schools_api/resources"
+)
+
+const (
+	bodyInvalidErrMsg     = "request body is not valid JSON"
+	responseInvalidErrMsg = "response does not match the API specification"
+)
+
+// Options configures a Validator.
+type Options struct {
+	// ValidateResponses checks each response against the specification as
+	// well as each request. Responses are buffered until they have been
+	// checked, so this is meant for testing and debugging. Responses that
+	// do not match are replaced by a 500 response.
+	ValidateResponses bool
+}
+
+// Validator checks requests and responses against an OpenAPI specification.
+type Validator struct {
+	router  routers.Router
+	options Options
+}
+
+// NewValidator returns a Validator with no specification. Its middleware has
+// to be added to a gin engine before the routes, while the specification is
+// generated from the routes, so the specification is set later with Load.
+func NewValidator(options Options) *Validator {
+	return &Validator{options: options}
+}
+
+// Load sets the specification that requests are checked against. Until it is
+// called every request is passed on unchecked.
+func (v *Validator) Load(doc *openapi3.T) error {
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return fmt.Errorf("invalid OpenAPI spec: %v", err)
+	}
+	v.router = router
+	return nil
+}
+
+// Middleware returns a gin middleware that rejects requests that do not match
+// the specification and, if ValidateResponses is set, checks the responses
+// of the handlers that follow it. Requests for paths that are not in the
+// specification, such as the documentation, are passed on unchecked.
+//
+// Only the shape of JSON request bodies is checked. The rules on their values
+// come from the binding tags of the request types and are left to the
+// handlers, which check them after normalizing the body, so that for example
+// a name of only spaces is reported as missing rather than accepted, and the
+// spaces around a name don't count towards its length.
+func (v *Validator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v.router == nil {
+			c.Next()
+			return
+		}
+		route, pathParams, err := v.router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				ExcludeRequestBody:         !checksBody(route.Operation, c.ContentType()),
+				ExcludeReadOnlyValidations: true,
+				SkipSettingDefaults:        true,
+				MultiError:                 true,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			if fields := describeRequestError(err); len(fields) > 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, resources.FieldErrors{Message: fields[0].Message, Errors: fields})
+				return
+			}
+		}
+
+		if !v.options.ValidateResponses {
+			c.Next()
+			return
+		}
+		validateResponse(c, input)
+	}
+}
+
+// validateResponse runs the handlers that follow the middleware, buffering
+// their response, and sends the response if it matches the specification or
+// a 500 response if it does not. Server errors are sent as they are, since
+// they are rarely described by the specification.
+func validateResponse(c *gin.Context, input *openapi3filter.RequestValidationInput) {
+	w := &bufferedWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+	c.Writer = w.ResponseWriter
+
+	status := w.Status()
+	if status < http.StatusInternalServerError {
+		err := openapi3filter.ValidateResponse(context.Background(), (&openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 status,
+			Header:                 w.Header(),
+			Options: &openapi3filter.Options{
+				// Only JSON bodies can be checked against the schemas.
+				ExcludeResponseBody:   !isJSON(w.Header().Get("Content-Type")),
+				IncludeResponseStatus: true,
+				MultiError:            true,
+			},
+		}).SetBodyBytes(w.body.Bytes()))
+		if err != nil {
+			log.Printf("%s %s: %s: %v", c.Request.Method, c.Request.URL, responseInvalidErrMsg, err)
+			for key := range w.Header() {
+				w.Header().Del(key)
+			}
+			c.JSON(http.StatusInternalServerError, resources.Error{Message: responseInvalidErrMsg + ": " + err.Error()})
+			return
+		}
+	}
+
+	c.Writer.WriteHeader(status)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(w.body.Bytes())
+}
+
+// checksBody reports whether a request body of mediaType is checked against
+// the schema of operation. Only JSON bodies of operations that accept them
+// are checked. The handlers reject bodies with other media types with more
+// helpful messages than the specification allows for, and imports are too
+// large to read twice.
+func checksBody(operation *openapi3.Operation, mediaType string) bool {
+	if mediaType != gin.MIMEJSON || operation.RequestBody == nil || operation.RequestBody.Value == nil {
+		return false
+	}
+	return operation.RequestBody.Value.Content.Get(mediaType) != nil
+}
+
+// isJSON reports whether contentType is application/json, ignoring any
+// parameters.
+func isJSON(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return mediaType == gin.MIMEJSON
+}
+
+// bufferedWriter holds a response in memory until it has been checked.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Flush does nothing, since the response cannot be sent before it has been
+// checked.
+func (w *bufferedWriter) Flush() {}
+
+// describeRequestError describes each problem found in a request by
+// ValidateRequest, leaving out those in the request body that the handlers
+// check after normalizing it.
+func describeRequestError(err error) []resources.FieldError {
+	var fields []resources.FieldError
+	switch actualErr := err.(type) {
+	case openapi3.MultiError:
+		for _, e := range actualErr {
+			fields = append(fields, describeRequestError(e)...)
+		}
+	case *openapi3filter.RequestError:
+		causes := []error{actualErr.Err}
+		if multi, ok := actualErr.Err.(openapi3.MultiError); ok {
+			causes = multi
+		}
+		for _, cause := range causes {
+			switch {
+			case actualErr.Parameter != nil:
+				fields = append(fields, resources.FieldError{
+					Field:   actualErr.Parameter.Name,
+					Message: describeParameterError(actualErr.Parameter, cause),
+				})
+			case actualErr.RequestBody != nil:
+				if !bindingRule(cause) {
+					fields = append(fields, describeBodyError(cause))
+				}
+			default:
+				fields = append(fields, resources.FieldError{Message: actualErr.Error()})
+			}
+		}
+	default:
+		fields = append(fields, resources.FieldError{Message: err.Error()})
+	}
+	return fields
+}
+
+// describeParameterError returns a message describing what is wrong with the
+// value of a parameter, such as "limit query parameter must be a number".
+func describeParameterError(param *openapi3.Parameter, err error) string {
+	var schema *openapi3.Schema
+	if param.Schema != nil {
+		schema = param.Schema.Value
+	}
+
+	var rule string
+	var schemaErr *openapi3.SchemaError
+	switch {
+	case errors.Is(err, openapi3filter.ErrInvalidRequired):
+		rule = "is required"
+	case errors.As(err, &schemaErr) && (schemaErr.SchemaField == "minimum" || schemaErr.SchemaField == "maximum"):
+		switch {
+		case schema.Min != nil && schema.Max != nil:
+			rule = fmt.Sprintf("must be at least %v and no greater than %v", *schema.Min, *schema.Max)
+		case schema.Min != nil:
+			rule = fmt.Sprintf("must be at least %v", *schema.Min)
+		default:
+			rule = fmt.Sprintf("must be no greater than %v", *schema.Max)
+		}
+	case errors.As(err, &schemaErr) && schemaErr.SchemaField == "enum":
+		rule = "must be one of " + joinEnum(schema.Enum)
+	case schema != nil && (schema.Type.Is("integer") || schema.Type.Is("number")):
+		rule = "must be a number"
+	case schema != nil && schema.Type.Is("boolean"):
+		rule = "must be true or false"
+	default:
+		rule = "is invalid"
+	}
+
+	switch param.In {
+	case openapi3.ParameterInPath:
+		return humanize(param.Name) + " " + rule
+	case openapi3.ParameterInQuery:
+		return param.Name + " query parameter " + rule
+	default:
+		return param.Name + " " + param.In + " " + rule
+	}
+}
+
+// bindingRules are the schema fields set by applyBinding from the binding
+// tags of a type.
+var bindingRules = map[string]bool{
+	"required":  true,
+	"minLength": true,
+	"maxLength": true,
+	"minItems":  true,
+	"maxItems":  true,
+	"minimum":   true,
+	"maximum":   true,
+	"enum":      true,
+	"pattern":   true,
+	"format":    true,
+}
+
+// bindingRule reports whether err is about a rule from the binding tags of the
+// request type, which the handlers check with validation.Struct.
+func bindingRule(err error) bool {
+	var schemaErr *openapi3.SchemaError
+	return errors.As(err, &schemaErr) && bindingRules[schemaErr.SchemaField]
+}
+
+// describeBodyError describes what is wrong with the shape of a JSON request
+// body, in the same words as the handlers use for invalid schools.
+func describeBodyError(err error) resources.FieldError {
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return resources.FieldError{Message: bodyInvalidErrMsg}
+	}
+	schema := schemaErr.Schema
+
+	var rule string
+	switch schemaErr.SchemaField {
+	case "type":
+		switch {
+		case schema.Type.Is("boolean"):
+			rule = "must be true or false"
+		case schema.Type.Is("integer"):
+			rule = "must be a number"
+		case schema.Type.Is("object") || schema.Type.Is("array"):
+			rule = "must be an " + schema.Type.Slice()[0]
+		default:
+			rule = "must be a " + strings.Join(schema.Type.Slice(), " or ")
+		}
+	default:
+		rule = "is invalid"
+	}
+
+	field := strings.Join(schemaErr.JSONPointer(), ".")
+	if field == "" {
+		return resources.FieldError{Message: "request body " + rule}
+	}
+	return resources.FieldError{Field: field, Message: fmt.Sprintf("Field %q %s", field, rule)}
+}
+
+// joinEnum lists the values allowed by an enum, such as "csv, jsonl, json".
+func joinEnum(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// humanize splits a camelCase name into lower case words, so that schoolID
+// becomes "school id".
+func humanize(name string) string {
+	var b strings.Builder
+	prevLower := false
+	for _, r := range name {
+		if unicode.IsUpper(r) && prevLower {
+			b.WriteRune(' ')
+		}
+		prevLower = unicode.IsLower(r)
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
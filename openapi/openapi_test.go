@@ -0,0 +1,227 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testSchool is a request and response body with the kinds of rules used by
+// resources.School.
+type testSchool struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name" binding:"required,max=255"`
+	Country string   `json:"country,omitempty" binding:"omitempty,iso3166_1_alpha2"`
+	Type    string   `json:"type,omitempty" binding:"omitempty,oneof=public private for-profit"`
+	Website string   `json:"website,omitempty" binding:"omitempty,http_url"`
+	Aliases []string `json:"aliases,omitempty" binding:"omitempty,min=1,max=3"`
+}
+
+// testHandler answers every request with status and body.
+type testHandler struct {
+	status int
+	body   interface{}
+}
+
+func (h *testHandler) respond(c *gin.Context) {
+	if h.body == nil {
+		c.Status(h.status)
+		return
+	}
+	c.Header("Vary", "Accept")
+	c.JSON(h.status, h.body)
+}
+
+func (h *testHandler) ListSchools(c *gin.Context)   { h.respond(c) }
+func (h *testHandler) AddSchool(c *gin.Context)     { h.respond(c) }
+func (h *testHandler) SearchSchools(c *gin.Context) { h.respond(c) }
+func (h *testHandler) GetSchool(c *gin.Context)     { h.respond(c) }
+func (h *testHandler) Other(c *gin.Context)         { h.respond(c) }
+
+var errorContent = map[string]interface{}{"application/json": struct {
+	Message string `json:"message"`
+}{}}
+
+var testAPI = API{
+	Info: &openapi3.Info{Title: "Test API", Version: "1.0.0"},
+	Operations: map[string]Operation{
+		"ListSchools": {
+			Parameters: []*openapi3.Parameter{
+				openapi3.NewQueryParameter("limit").WithSchema(openapi3.NewIntegerSchema().WithMin(1).WithMax(100)),
+				openapi3.NewQueryParameter("offset").WithSchema(openapi3.NewIntegerSchema().WithMin(0)),
+				openapi3.NewQueryParameter("cursor").WithSchema(openapi3.NewStringSchema()),
+				openapi3.NewQueryParameter("sort").WithSchema(openapi3.NewStringSchema().WithEnum("id", "-id", "name", "-name")),
+				openapi3.NewQueryParameter("deleted").WithSchema(openapi3.NewBoolSchema()),
+			},
+			Responses: []Response{{Status: http.StatusNoContent, Description: "No content."}},
+		},
+		"AddSchool": {
+			Body:      &Body{Content: map[string]interface{}{"application/json": testSchool{}}},
+			Responses: []Response{{Status: http.StatusNoContent, Description: "No content."}},
+		},
+		"SearchSchools": {
+			Parameters: []*openapi3.Parameter{
+				openapi3.NewQueryParameter("q").WithRequired(true).WithSchema(openapi3.NewStringSchema()),
+			},
+			Responses: []Response{{Status: http.StatusNoContent, Description: "No content."}},
+		},
+		"GetSchool": {
+			Parameters: []*openapi3.Parameter{
+				openapi3.NewPathParameter("schoolID").WithSchema(openapi3.NewIntegerSchema()),
+			},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "A school.", Headers: map[string]string{"Vary": "Accept"}, Content: map[string]interface{}{"application/json": testSchool{}}},
+				{Status: http.StatusNoContent, Description: "No content."},
+				{Status: http.StatusNotFound, Description: "Not found.", Content: errorContent},
+				{Status: http.StatusInternalServerError, Description: "Internal server error.", Content: errorContent},
+			},
+		},
+	},
+	Schemas: map[string]Schema{
+		"testSchool": {
+			Description: "A school.",
+			Fields:      map[string]string{"name": "The name of the school."},
+			ReadOnly:    []string{"id"},
+		},
+	},
+}
+
+// newEngine returns a gin engine with the routes of testAPI, plus a route
+// that is not in the specification, that checks requests against the
+// specification generated from them and answers them with h.
+func newEngine(t *testing.T, options Options, h *testHandler) *gin.Engine {
+	t.Helper()
+	v := NewValidator(options)
+	r := gin.New()
+	r.Use(v.Middleware())
+	r.GET("/schools", h.ListSchools)
+	r.POST("/schools", h.AddSchool)
+	r.GET("/schools/search", h.SearchSchools)
+	r.GET("/schools/:schoolID", h.GetSchool)
+
+	doc, err := Generate(testAPI, r.Routes())
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if err := v.Load(doc); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	r.GET("/other", h.Other)
+	return r
+}
+
+func serve(r *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequests(t *testing.T) {
+	r := newEngine(t, Options{}, &testHandler{status: http.StatusNoContent})
+
+	tests := []struct {
+		method, target, body string
+		want                 string
+		fields               []string
+	}{
+		{"GET", "/schools?limit=10&offset=20&sort=-name&deleted=true", "", "", nil},
+		{"GET", "/schools?cursor=", "", "", nil},
+		{"GET", "/schools?limit=abc", "", "limit query parameter must be a number", []string{"limit"}},
+		{"GET", "/schools?limit=0", "", "limit query parameter must be at least 1 and no greater than 100", []string{"limit"}},
+		{"GET", "/schools?offset=-1", "", "offset query parameter must be at least 0", []string{"offset"}},
+		{"GET", "/schools?deleted=maybe", "", "deleted query parameter must be true or false", []string{"deleted"}},
+		{"GET", "/schools?sort=city", "", "sort query parameter must be one of id, -id, name, -name", []string{"sort"}},
+		{"GET", "/schools?limit=abc&sort=city", "", "limit query parameter must be a number", []string{"limit", "sort"}},
+		{"GET", "/schools/search", "", "q query parameter is required", []string{"q"}},
+		{"GET", "/schools/12", "", "", nil},
+		{"GET", "/schools/abc", "", "school id must be a number", []string{"schoolID"}},
+		{"POST", "/schools", `{"name": "Auburn University", "id": 12}`, "", nil},
+		{"POST", "/schools", `{"name": 42}`, `Field "name" must be a string`, []string{"name"}},
+		{"POST", "/schools", `{"name": "Auburn University", "aliases": "Auburn"}`, `Field "aliases" must be an array`, []string{"aliases"}},
+		// The rules from the binding tags are left to the handler, which
+		// checks them after normalizing the body.
+		{"POST", "/schools", `{"country": "US"}`, "", nil},
+		{"POST", "/schools", `{"name": " "}`, "", nil},
+		{"POST", "/schools", `{"name": "  ` + strings.Repeat("a", 255) + `  "}`, "", nil},
+		{"POST", "/schools", `{"name": "Auburn University", "country": "USA"}`, "", nil},
+		{"POST", "/schools", `{"name": 42, "type": "charter"}`, `Field "name" must be a string`, []string{"name"}},
+		{"POST", "/schools", `["Auburn University"]`, "request body must be an object", []string{""}},
+		{"POST", "/schools", `{"name": `, bodyInvalidErrMsg, []string{""}},
+		{"GET", "/other?limit=abc", "", "", nil},
+	}
+	for _, tt := range tests {
+		w := serve(r, tt.method, tt.target, tt.body)
+		if tt.want == "" {
+			if w.Code != http.StatusNoContent {
+				t.Errorf("%s %s: status = %d, want %d: %s", tt.method, tt.target, w.Code, http.StatusNoContent, w.Body)
+			}
+			continue
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.target, w.Code, http.StatusBadRequest)
+			continue
+		}
+		var resp struct {
+			Message string
+			Errors  []struct{ Field, Message string }
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s %s: invalid response %s: %v", tt.method, tt.target, w.Body, err)
+			continue
+		}
+		if resp.Message != tt.want {
+			t.Errorf("%s %s: message = %q, want %q", tt.method, tt.target, resp.Message, tt.want)
+		}
+		var fields []string
+		for _, e := range resp.Errors {
+			fields = append(fields, e.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+			t.Errorf("%s %s: fields = %q, want %q", tt.method, tt.target, fields, tt.fields)
+		}
+	}
+}
+
+func TestResponses(t *testing.T) {
+	tests := []struct {
+		status int
+		body   interface{}
+		want   int
+	}{
+		{http.StatusOK, gin.H{"id": 12, "name": "Auburn University"}, http.StatusOK},
+		{http.StatusOK, gin.H{"id": "12", "name": "Auburn University"}, http.StatusInternalServerError},
+		{http.StatusNoContent, nil, http.StatusNoContent},
+		{http.StatusNotFound, gin.H{"message": "School with id 12 not found"}, http.StatusNotFound},
+		{http.StatusTeapot, gin.H{"message": "short and stout"}, http.StatusInternalServerError},
+		{http.StatusInternalServerError, gin.H{"message": "internal server error"}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		r := newEngine(t, Options{ValidateResponses: true}, &testHandler{status: tt.status, body: tt.body})
+
+		w := serve(r, "GET", "/schools/12", "")
+		if w.Code != tt.want {
+			t.Errorf("%d %v: status = %d, want %d: %s", tt.status, tt.body, w.Code, tt.want, w.Body)
+			continue
+		}
+		if tt.want == tt.status && tt.body != nil {
+			want, _ := json.Marshal(tt.body)
+			if w.Body.String() != string(want) {
+				t.Errorf("%d %v: body = %s, want %s", tt.status, tt.body, w.Body, want)
+			}
+		}
+	}
+}
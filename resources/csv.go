@@ -0,0 +1,51 @@
+package resources
+
+import (
+	"strconv"
+)
+
+// csvColumns are the CSV columns of the string fields of a school, in order.
+// They have the same names as the JSON fields.
+var csvColumns = []string{
+	"name",
+	"city",
+	"state",
+	"postal_code",
+	"country",
+	"type",
+	"level",
+	"website",
+	"external_id",
+}
+
+// CSVFields returns the string fields of s by the name of their CSV column.
+func (s *School) CSVFields() map[string]*string {
+	return map[string]*string{
+		"name":        &s.Name,
+		"city":        &s.City,
+		"state":       &s.State,
+		"postal_code": &s.PostalCode,
+		"country":     &s.Country,
+		"type":        &s.Type,
+		"level":       &s.Level,
+		"website":     &s.Website,
+		"external_id": &s.ExternalID,
+	}
+}
+
+// CSVHeader returns the header row of a CSV file of schools.
+func CSVHeader() []string {
+	return append([]string{"id"}, csvColumns...)
+}
+
+// CSVRecord returns the row of s in a CSV file of schools, with the columns
+// in the order of CSVHeader.
+func (s *School) CSVRecord() []string {
+	fields := s.CSVFields()
+	record := make([]string, 0, len(csvColumns)+1)
+	record = append(record, strconv.Itoa(s.ID))
+	for _, column := range csvColumns {
+		record = append(record, *fields[column])
+	}
+	return record
+}
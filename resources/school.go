@@ -1,29 +1,218 @@
 package resources
 
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/clinstid/schools_api/db"
+)
+
 // School is the frontend representation of a single school.
 type School struct {
-	ID   int    `json:"id"`
-	Name string `json:"name" binding:"required""`
+	XMLName    xml.Name   `json:"-" xml:"school" yaml:"-"`
+	ID         int        `json:"id" xml:"id" yaml:"id"`
+	Name       string     `json:"name" xml:"name" yaml:"name" binding:"required,max=255,nocontrol"`
+	City       string     `json:"city,omitempty" xml:"city,omitempty" yaml:"city,omitempty" binding:"omitempty,max=100,nocontrol"`
+	State      string     `json:"state,omitempty" xml:"state,omitempty" yaml:"state,omitempty" binding:"omitempty,max=100,nocontrol"`
+	PostalCode string     `json:"postal_code,omitempty" xml:"postal_code,omitempty" yaml:"postal_code,omitempty" binding:"omitempty,max=20,nocontrol"`
+	Country    string     `json:"country,omitempty" xml:"country,omitempty" yaml:"country,omitempty" binding:"omitempty,iso3166_1_alpha2"`
+	Type       string     `json:"type,omitempty" xml:"type,omitempty" yaml:"type,omitempty" binding:"omitempty,oneof=public private for-profit"`
+	Level      string     `json:"level,omitempty" xml:"level,omitempty" yaml:"level,omitempty" binding:"omitempty,oneof=2-year 4-year"`
+	Website    string     `json:"website,omitempty" xml:"website,omitempty" yaml:"website,omitempty" binding:"omitempty,max=2048,http_url"`
+	ExternalID string     `json:"external_id,omitempty" xml:"external_id,omitempty" yaml:"external_id,omitempty" binding:"omitempty,max=64,printascii"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+}
+
+// Normalize trims leading and trailing whitespace from the fields of s. It is
+// called before s is validated, so the length limits apply to the trimmed
+// values and a name of only whitespace is rejected as missing.
+func (s *School) Normalize() {
+	for _, field := range s.CSVFields() {
+		*field = strings.TrimSpace(*field)
+	}
+}
+
+// NewSchool converts a school from the db.SchoolStore to its frontend
+// representation.
+func NewSchool(school *db.School) School {
+	return School{
+		ID:         school.ID,
+		Name:       school.Name,
+		City:       school.City,
+		State:      school.State,
+		PostalCode: school.PostalCode,
+		Country:    school.Country,
+		Type:       school.Type,
+		Level:      school.Level,
+		Website:    school.Website,
+		ExternalID: school.ExternalID,
+		DeletedAt:  school.DeletedAt,
+	}
+}
+
+// StoreSchool converts a school from a request to the record held in the
+// db.SchoolStore. The id is passed separately since it comes from the request
+// path, or the store, rather than the body.
+func (s *School) StoreSchool(id int) db.School {
+	return db.School{
+		ID:         id,
+		Name:       s.Name,
+		City:       s.City,
+		State:      s.State,
+		PostalCode: s.PostalCode,
+		Country:    s.Country,
+		Type:       s.Type,
+		Level:      s.Level,
+		Website:    s.Website,
+		ExternalID: s.ExternalID,
+	}
 }
 
 // Schools is the frontend representation of a paginated collection of schools.
 type Schools struct {
-	Schools []School `json:"schools"`
-	Meta    Meta     `json:"meta"`
-	Links   Links    `json:"links"`
+	XMLName xml.Name `json:"-" xml:"schools" yaml:"-"`
+	Schools []School `json:"schools" xml:"school" yaml:"schools"`
+	Meta    Meta     `json:"meta" xml:"meta" yaml:"meta"`
+	Links   Links    `json:"links" xml:"links" yaml:"links"`
 }
 
 // Meta is the frontend reprensetation of the metadata associated with a
 // collection including the total number of schools in the database.
 type Meta struct {
-	Total int `json:"total"`
+	Total int `json:"total" xml:"total" yaml:"total"`
+
+	// NextCursor is the cursor for the next page when paginating with
+	// cursors. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty" xml:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
 }
 
 // Links is the frontend representation of a set of URLs that represent
 // different pages of interest within a paginated collection.
 type Links struct {
-	First string `json:"first,omitempty"`
-	Last  string `json:"last,omitempty"`
-	Next  string `json:"next,omitempty"`
-	Prev  string `json:"prev,omitempty"`
+	First string `json:"first,omitempty" xml:"first,omitempty" yaml:"first,omitempty"`
+	Last  string `json:"last,omitempty" xml:"last,omitempty" yaml:"last,omitempty"`
+	Next  string `json:"next,omitempty" xml:"next,omitempty" yaml:"next,omitempty"`
+	Prev  string `json:"prev,omitempty" xml:"prev,omitempty" yaml:"prev,omitempty"`
+}
+
+// Error is the frontend representation of an error.
+type Error struct {
+	Message string `json:"message"`
+}
+
+// FieldError is the frontend representation of a field of a request body
+// that is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors is the frontend representation of a request with invalid
+// fields or parameters. Message describes the first of them.
+type FieldErrors struct {
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// Conflict is the frontend representation of a conflict with an existing
+// school, such as a school that already has a name.
+type Conflict struct {
+	Message string `json:"message"`
+	ID      int    `json:"id"`
+	Link    string `json:"link"`
+}
+
+// SearchResult is the frontend representation of a school matched by a
+// search along with its relevance score.
+type SearchResult struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// SearchResults is the frontend representation of the schools matched by a
+// search, most relevant first.
+type SearchResults struct {
+	Results []SearchResult `json:"results"`
+	Meta    Meta           `json:"meta"`
+}
+
+// Suggestions is the frontend representation of the schools suggested for a
+// partially typed name.
+type Suggestions struct {
+	Suggestions []School `json:"suggestions"`
+}
+
+// ImportResult is the frontend representation of a successful import.
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// RowError is the frontend representation of a problem with one row of an
+// import.
+type RowError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportErrors is the frontend representation of an import that was rejected
+// because of problems with some of its rows.
+type ImportErrors struct {
+	Message string     `json:"message"`
+	Errors  []RowError `json:"errors"`
+}
+
+// Modes of a batch
+const (
+	// BatchAtomic applies every operation of a batch or, if any of them
+	// fails, none of them.
+	BatchAtomic = "atomic"
+
+	// BatchPartial applies each operation of a batch on its own, whether or
+	// not the others fail.
+	BatchPartial = "partial"
+)
+
+// Operations of a batch
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// Batch is the frontend representation of a batch of changes to schools.
+type Batch struct {
+	Mode       string           `json:"mode,omitempty" binding:"omitempty,oneof=atomic partial"`
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+// BatchOperation is the frontend representation of one change in a batch.
+// ID is required to update or delete a school and School to create or update
+// one.
+type BatchOperation struct {
+	Op      string  `json:"op" binding:"required,oneof=create update delete"`
+	ID      *int    `json:"id,omitempty"`
+	IfMatch string  `json:"if_match,omitempty"`
+	School  *School `json:"school,omitempty"`
+}
+
+// BatchResult is the frontend representation of the outcome of one operation
+// of a batch. Status is the status of the response to the same change made on
+// its own, ETag its ETag header and School or Error its body.
+type BatchResult struct {
+	Status int         `json:"status"`
+	ID     *int        `json:"id,omitempty"`
+	ETag   string      `json:"etag,omitempty"`
+	School *School     `json:"school,omitempty"`
+	Error  interface{} `json:"error,omitempty"`
+}
+
+// BatchResults is the frontend representation of the outcome of a batch, with
+// one result for each operation in the same order.
+type BatchResults struct {
+	Message string        `json:"message,omitempty"`
+	Results []BatchResult `json:"results"`
 }
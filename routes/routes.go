@@ -3,23 +3,69 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 
+	"github.com/clinstid/schools_api/cursor"
+	"github.com/clinstid/schools_api/db"
 	"github.com/clinstid/schools_api/handlers"
+	"github.com/clinstid/schools_api/openapi"
+	"github.com/clinstid/schools_api/search"
+	"github.com/clinstid/schools_api/validation"
 )
 
-// SetupRouter adds routes to a gin HTTP server
-func SetupRouter() *gin.Engine {
+// Options configures the handlers and the checks made against the OpenAPI
+// specification.
+type Options struct {
+	Handlers handlers.Options
+	OpenAPI  openapi.Options
+}
+
+// SetupRouter adds routes to a gin HTTP server. The handlers use store to look
+// up and modify schools, index to search for them and cursors to sign
+// pagination cursors. The OpenAPI specification is generated from the routes
+// and served at /openapi.json, and requests are checked against it before
+// they reach the handlers. It returns an error if any of the routes is not
+// described by handlers.API. Request bodies are checked as described by
+// validation.Install.
+func SetupRouter(store db.SchoolStore, index *search.Index, cursors *cursor.Codec, options Options) (*gin.Engine, error) {
+	validation.Install()
+
 	r := gin.Default()
+	validator := openapi.NewValidator(options.OpenAPI)
+	r.Use(validator.Middleware())
+	h := handlers.New(store, index, cursors, options.Handlers)
 
 	// /schools routes
-	r.GET("/schools", handlers.ListSchools)
-	r.POST("/schools", handlers.AddSchool)
+	r.GET("/schools", h.ListSchools)
+	r.POST("/schools", h.Idempotent, h.AddSchool)
+	r.POST("/schools/import", h.ImportSchools)
+	r.POST("/schools/batch", h.Idempotent, h.BatchSchools)
+	r.GET("/schools/export", h.ExportSchools)
+	r.GET("/schools/search", h.SearchSchools)
+	r.GET("/schools/autocomplete", h.AutocompleteSchools)
 
 	// /schools/{id} routes
-	r.GET("/schools/:schoolID", handlers.GetSchool)
-	r.PUT("/schools/:schoolID", handlers.UpdateSchool)
+	r.GET("/schools/:schoolID", h.GetSchool)
+	r.PUT("/schools/:schoolID", h.UpdateSchool)
+	r.PATCH("/schools/:schoolID", h.PatchSchool)
+	r.DELETE("/schools/:schoolID", h.DeleteSchool)
+	r.POST("/schools/:schoolID/restore", h.RestoreSchool)
+
+	// Every API route must be described by the specification. The routes
+	// added after it is generated are not part of the API.
+	doc, err := openapi.Generate(handlers.API, r.Routes())
+	if err != nil {
+		return nil, err
+	}
+	if err := validator.Load(doc); err != nil {
+		return nil, err
+	}
+	serveSpec, err := openapi.Handler(doc)
+	if err != nil {
+		return nil, err
+	}
 
 	// Documentation routes
+	r.GET("/openapi.json", serveSpec)
 	r.Static("/docs/", "./dist/")
 
-	return r
+	return r, nil
 }
@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/clinstid/schools_api/cursor"
+	"github.com/clinstid/schools_api/db"
+	"github.com/clinstid/schools_api/handlers"
+	"github.com/clinstid/schools_api/search"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r, err := SetupRouter(db.NewMemoryStore(), search.NewIndex(), cursor.NewCodec([]byte("secret")), Options{})
+	if err != nil {
+		t.Fatalf("SetupRouter() error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status = %d", w.Code)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid /openapi.json: %v", err)
+	}
+
+	// Every route of the API is in the spec, under the name of its handler.
+	for _, route := range r.Routes() {
+		if route.Path == "/openapi.json" || route.Path == "/docs/*filepath" {
+			continue
+		}
+		path := strings.Replace(route.Path, ":schoolID", "{schoolID}", 1)
+		op, ok := doc.Paths[path][strings.ToLower(route.Method)]
+		if !ok || op.OperationID == "" {
+			t.Errorf("%s %s is not in /openapi.json", route.Method, route.Path)
+		}
+	}
+}
+
+// etagOf returns a function that returns the ETag of a school at a version in
+// store.
+func etagOf(t *testing.T, store db.SchoolStore) func(version int) string {
+	rev, err := store.Revision(context.Background())
+	if err != nil {
+		t.Fatalf("Revision() error: %v", err)
+	}
+	return func(version int) string {
+		return fmt.Sprintf(`"%s-%d"`, rev.Epoch, version)
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	options := Options{Handlers: handlers.Options{RequireIfMatch: true}}
+	store := db.NewMemoryStore()
+	r, err := SetupRouter(store, search.NewIndex(), cursor.NewCodec([]byte("secret")), options)
+	if err != nil {
+		t.Fatalf("SetupRouter() error: %v", err)
+	}
+	tag := etagOf(t, store)
+	serve := func(method, target, ifMatch string) *httptest.ResponseRecorder {
+		var body io.Reader
+		if method == "PUT" {
+			body = strings.NewReader(`{"name": "If-Match Test University"}`)
+		}
+		req := httptest.NewRequest(method, target, body)
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	etag := serve("GET", "/schools/1", "").Header().Get("ETag")
+	if etag != tag(1) {
+		t.Fatalf("GET /schools/1 ETag = %q, want %q", etag, tag(1))
+	}
+
+	tests := []struct {
+		method, ifMatch string
+		want            int
+	}{
+		{"PUT", "", http.StatusPreconditionRequired},
+		{"DELETE", "", http.StatusPreconditionRequired},
+		{"PUT", "W/" + tag(1), http.StatusPreconditionFailed},
+		// The version alone, as from another store, does not match
+		{"PUT", `"1"`, http.StatusPreconditionFailed},
+		{"PUT", tag(0) + ", " + tag(1), http.StatusOK},
+		{"PUT", tag(1), http.StatusPreconditionFailed},
+		{"DELETE", tag(2), http.StatusNoContent},
+		{"DELETE", "*", http.StatusGone},
+	}
+	for _, tt := range tests {
+		w := serve(tt.method, "/schools/1", tt.ifMatch)
+		if w.Code != tt.want {
+			t.Errorf("%s /schools/1 with If-Match %s: status = %d, want %d: %s", tt.method, tt.ifMatch, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestBatchSchools(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := db.NewMemoryStore()
+	r, err := SetupRouter(store, search.NewIndex(), cursor.NewCodec([]byte("secret")), Options{})
+	if err != nil {
+		t.Fatalf("SetupRouter() error: %v", err)
+	}
+	tag := etagOf(t, store)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The update fails because school 1 is at version 1
+	operations := `[
+		{"op": "create", "school": {"name": "Batch Test University"}},
+		{"op": "update", "id": 1, "if_match": "\"2\"", "school": {"name": "Batch Test College"}},
+		{"op": "delete", "id": 2}
+	]`
+	tests := []struct {
+		mode     string
+		status   int
+		statuses []int
+		added    int
+	}{
+		{"atomic", http.StatusUnprocessableEntity, []int{http.StatusFailedDependency, http.StatusPreconditionFailed, http.StatusFailedDependency}, 0},
+		{"partial", http.StatusOK, []int{http.StatusCreated, http.StatusPreconditionFailed, http.StatusNoContent}, 1},
+	}
+	for _, tt := range tests {
+		w := serve("POST", "/schools/batch", `{"mode": "`+tt.mode+`", "operations": `+operations+`}`)
+		if w.Code != tt.status {
+			t.Fatalf("%s batch: status = %d, want %d: %s", tt.mode, w.Code, tt.status, w.Body)
+		}
+		var body struct {
+			Results []struct {
+				Status int
+				ETag   string
+			}
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s batch: invalid response: %v", tt.mode, err)
+		}
+		if len(body.Results) != len(tt.statuses) {
+			t.Fatalf("%s batch: %d results, want %d", tt.mode, len(body.Results), len(tt.statuses))
+		}
+		for i, result := range body.Results {
+			if result.Status != tt.statuses[i] {
+				t.Errorf("%s batch: operation %d status = %d, want %d", tt.mode, i, result.Status, tt.statuses[i])
+			}
+		}
+		if etag := body.Results[1].ETag; etag != tag(1) {
+			t.Errorf("%s batch: failed update ETag = %q, want %q", tt.mode, etag, tag(1))
+		}
+
+		var list struct {
+			Meta struct{ Total int }
+		}
+		json.Unmarshal(serve("GET", "/schools?exact=Batch+Test+University", "").Body.Bytes(), &list)
+		if list.Meta.Total != tt.added {
+			t.Errorf("after %s batch: %d schools added, want %d", tt.mode, list.Meta.Total, tt.added)
+		}
+	}
+	// The school created by the first operation was rolled back, so the
+	// conflict with it is described without its id
+	w := serve("POST", "/schools/batch", `{"operations": [
+		{"op": "create", "school": {"name": "Batch Rollback University"}},
+		{"op": "create", "school": {"name": "Batch Rollback University"}}
+	]}`)
+	var body struct {
+		Results []struct {
+			Status int
+			Error  map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusUnprocessableEntity || len(body.Results) != 2 {
+		t.Fatalf("conflicting batch = %d %s, want %d", w.Code, w.Body, http.StatusUnprocessableEntity)
+	}
+	want := map[string]interface{}{"message": `School created by operation 0 already has the name "Batch Rollback University"`}
+	if got := body.Results[1]; got.Status != http.StatusConflict || !reflect.DeepEqual(got.Error, want) {
+		t.Errorf("conflicting batch: operation 1 = %d %v, want %d %v", got.Status, got.Error, http.StatusConflict, want)
+	}
+}
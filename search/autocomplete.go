@@ -0,0 +1,139 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// completion is an entry in one of the sorted prefix indexes used by
+// Autocomplete. key is a normalized name, or the tail of one starting at a
+// word, and id is the school it belongs to.
+type completion struct {
+	key string
+	id  int
+}
+
+// completionKeys returns the normalized form of name, its lower case words
+// separated by single spaces, and the tails of that form starting at each
+// word after the first.
+func completionKeys(name string) (string, []string) {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tails := make([]string, 0, len(words))
+	for i := 1; i < len(words); i++ {
+		tails = append(tails, strings.Join(words[i:], " "))
+	}
+	return strings.Join(words, " "), tails
+}
+
+// normalizePrefix normalizes a prefix typed by a user the same way names are
+// normalized, keeping a trailing space so that "saint " does not match
+// "saints".
+func normalizePrefix(prefix string) string {
+	key, _ := completionKeys(prefix)
+	if key != "" && strings.TrimRightFunc(prefix, unicode.IsSpace) != prefix {
+		key += " "
+	}
+	return key
+}
+
+// searchCompletions returns the position of the first entry in entries that is not less
+// than c.
+func searchCompletions(entries []completion, c completion) int {
+	return sort.Search(len(entries), func(i int) bool {
+		e := entries[i]
+		return e.key > c.key || (e.key == c.key && e.id >= c.id)
+	})
+}
+
+// insertCompletion adds c to the sorted entries.
+func insertCompletion(entries []completion, c completion) []completion {
+	i := searchCompletions(entries, c)
+	entries = append(entries, completion{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = c
+	return entries
+}
+
+// removeCompletion removes c from the sorted entries.
+func removeCompletion(entries []completion, c completion) []completion {
+	i := searchCompletions(entries, c)
+	if i < len(entries) && entries[i] == c {
+		entries = append(entries[:i], entries[i+1:]...)
+	}
+	return entries
+}
+
+// addCompletions adds the prefix index entries for the school with the
+// specified id and name. The caller must hold idx.mu.
+func (idx *Index) addCompletions(id int, name string) {
+	key, tails := completionKeys(name)
+	idx.leading = insertCompletion(idx.leading, completion{key: key, id: id})
+	for _, tail := range tails {
+		idx.wordStarts = insertCompletion(idx.wordStarts, completion{key: tail, id: id})
+	}
+}
+
+// removeCompletions removes the prefix index entries for the school with the
+// specified id and name. The caller must hold idx.mu.
+func (idx *Index) removeCompletions(id int, name string) {
+	key, tails := completionKeys(name)
+	idx.leading = removeCompletion(idx.leading, completion{key: key, id: id})
+	for _, tail := range tails {
+		idx.wordStarts = removeCompletion(idx.wordStarts, completion{key: tail, id: id})
+	}
+}
+
+// rebuildCompletions rebuilds the prefix indexes from scratch for every
+// indexed school. The caller must hold idx.mu.
+func (idx *Index) rebuildCompletions() {
+	idx.leading = idx.leading[:0]
+	idx.wordStarts = idx.wordStarts[:0]
+	for id, doc := range idx.docs {
+		key, tails := completionKeys(doc.name)
+		idx.leading = append(idx.leading, completion{key: key, id: id})
+		for _, tail := range tails {
+			idx.wordStarts = append(idx.wordStarts, completion{key: tail, id: id})
+		}
+	}
+
+	for _, entries := range [][]completion{idx.leading, idx.wordStarts} {
+		sort.Slice(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			return a.key < b.key || (a.key == b.key && a.id < b.id)
+		})
+	}
+}
+
+// Autocomplete returns up to limit schools whose names start with prefix,
+// ignoring case and punctuation, followed by schools with a later word in
+// their name that starts with prefix. Each group is ordered by name.
+func (idx *Index) Autocomplete(prefix string, limit int) []Result {
+	key := normalizePrefix(prefix)
+	if key == "" {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	results := make([]Result, 0, limit)
+	seen := make(map[int]bool, limit)
+	for _, entries := range [][]completion{idx.leading, idx.wordStarts} {
+		for i := searchCompletions(entries, completion{key: key}); i < len(entries) && len(results) < limit; i++ {
+			e := entries[i]
+			if !strings.HasPrefix(e.key, key) {
+				break
+			}
+			if seen[e.id] {
+				continue
+			}
+			seen[e.id] = true
+			results = append(results, Result{ID: e.id, Name: idx.docs[e.id].name})
+		}
+	}
+	return results
+}
@@ -0,0 +1,258 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/clinstid/schools_api/db"
+)
+
+const (
+	// minScore is the lowest relevance score returned by Search.
+	minScore = 0.5
+
+	// loadPageSize is the number of schools read per page by Load.
+	loadPageSize = 1000
+)
+
+// Result is a single school matched by Search.
+type Result struct {
+	ID   int
+	Name string
+
+	// Score is the relevance of the school to the query between 0 and 1.
+	Score float64
+}
+
+// document is a school as it is held in the Index.
+type document struct {
+	name   string
+	tokens []string
+}
+
+// Index is an in-memory, typo-tolerant search index over school names. Names
+// are tokenized and abbreviations are expanded, and query terms are matched
+// against indexed terms that share a trigram with them. The Index also keeps
+// sorted prefix indexes over the names for Autocomplete. Index is safe for
+// concurrent use.
+type Index struct {
+	mu sync.RWMutex
+
+	// docs holds the indexed schools by id.
+	docs map[int]document
+
+	// postings maps each indexed term to the ids of the schools that
+	// contain it.
+	postings map[string]map[int]struct{}
+
+	// grams maps each trigram to the indexed terms that contain it.
+	grams map[string]map[string]struct{}
+
+	// leading and wordStarts are the sorted prefix indexes used by
+	// Autocomplete. leading holds each normalized name and wordStarts holds
+	// the tails of each name starting at its second and later words.
+	leading    []completion
+	wordStarts []completion
+}
+
+// NewIndex returns an empty Index.
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[int]document),
+		postings: make(map[string]map[int]struct{}),
+		grams:    make(map[string]map[string]struct{}),
+	}
+}
+
+// Load adds every active school in store to the index.
+func (idx *Index) Load(ctx context.Context, store db.SchoolStore) error {
+	for offset := 0; ; offset += loadPageSize {
+		page, err := store.GetSchools(ctx, db.ListOptions{Limit: loadPageSize, Offset: offset})
+		if err != nil {
+			return err
+		}
+		idx.mu.Lock()
+		for _, school := range page.Schools {
+			idx.remove(school.ID)
+			idx.addTerms(school.ID, school.Name)
+		}
+		idx.mu.Unlock()
+
+		if offset+loadPageSize >= page.Total {
+			break
+		}
+	}
+
+	// Inserting in to the sorted prefix indexes one school at a time is
+	// slow, so they are built in one go once all of the schools are loaded.
+	idx.mu.Lock()
+	idx.rebuildCompletions()
+	idx.mu.Unlock()
+	return nil
+}
+
+// Add indexes the school with the specified id and name, replacing any
+// previously indexed name for the id.
+func (idx *Index) Add(id int, name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+	idx.addTerms(id, name)
+	idx.addCompletions(id, name)
+}
+
+// addTerms adds the school with the specified id and name to the documents
+// and the term indexes. The caller must hold idx.mu.
+func (idx *Index) addTerms(id int, name string) {
+	doc := document{name: name, tokens: tokenize(name)}
+	idx.docs[id] = doc
+	for _, token := range doc.tokens {
+		for _, term := range expand(token) {
+			ids, ok := idx.postings[term]
+			if !ok {
+				ids = make(map[int]struct{})
+				idx.postings[term] = ids
+				for _, g := range trigrams(term) {
+					if idx.grams[g] == nil {
+						idx.grams[g] = make(map[string]struct{})
+					}
+					idx.grams[g][term] = struct{}{}
+				}
+			}
+			ids[id] = struct{}{}
+		}
+	}
+}
+
+// Remove drops the school with the specified id from the index.
+func (idx *Index) Remove(id int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+// remove drops the school with the specified id from the index. The caller
+// must hold idx.mu.
+func (idx *Index) remove(id int) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	delete(idx.docs, id)
+	idx.removeCompletions(id, doc.name)
+
+	for _, token := range doc.tokens {
+		for _, term := range expand(token) {
+			ids := idx.postings[term]
+			delete(ids, id)
+			if len(ids) > 0 {
+				continue
+			}
+
+			// No school uses the term anymore
+			delete(idx.postings, term)
+			for _, g := range trigrams(term) {
+				delete(idx.grams[g], term)
+				if len(idx.grams[g]) == 0 {
+					delete(idx.grams, g)
+				}
+			}
+		}
+	}
+}
+
+// candidates returns the indexed terms that could match the query term q.
+// The caller must hold idx.mu.
+func (idx *Index) candidates(q string) map[string]struct{} {
+	terms := make(map[string]struct{})
+	if _, ok := idx.postings[q]; ok {
+		terms[q] = struct{}{}
+	}
+	if len([]rune(q)) < 3 {
+		return terms
+	}
+
+	for _, g := range trigrams(q) {
+		for term := range idx.grams[g] {
+			terms[term] = struct{}{}
+		}
+	}
+	return terms
+}
+
+// Search returns up to limit schools matching query, most relevant first,
+// along with the total number of matching schools.
+func (idx *Index) Search(query string, limit int) ([]Result, int) {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil, 0
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Add up the best similarity of each query token to a term in each school
+	sums := make(map[int]float64)
+	matched := make(map[int]int)
+	for _, token := range tokens {
+		best := make(map[int]float64)
+		for _, q := range expand(token) {
+			for term := range idx.candidates(q) {
+				sim := similarity(q, term)
+				if sim == 0 {
+					continue
+				}
+				for id := range idx.postings[term] {
+					if sim > best[id] {
+						best[id] = sim
+					}
+				}
+			}
+		}
+		for id, sim := range best {
+			sums[id] += sim
+			matched[id]++
+		}
+	}
+
+	// Score each school by how much of the query it matches and, to a lesser
+	// degree, how much of its name the query covers
+	results := make([]Result, 0, len(sums))
+	for id, sum := range sums {
+		doc := idx.docs[id]
+		coverage := float64(matched[id]) / float64(len(doc.tokens))
+		if coverage > 1 {
+			coverage = 1
+		}
+		score := 0.8*sum/float64(len(tokens)) + 0.2*coverage
+		if score < minScore {
+			continue
+		}
+		results = append(results, Result{
+			ID:    id,
+			Name:  doc.name,
+			Score: math.Round(score*1000) / 1000,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, total
+}
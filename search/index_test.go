@@ -0,0 +1,263 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/clinstid/schools_api/db"
+)
+
+// newTestIndex returns an Index loaded with the seeded schools.
+func newTestIndex(t *testing.T) (*Index, db.SchoolStore) {
+	store := db.NewMemoryStore()
+	index := NewIndex()
+	if err := index.Load(context.Background(), store); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return index, NewIndexedStore(store, index)
+}
+
+func TestSearch(t *testing.T) {
+	index, _ := newTestIndex(t)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Univ of Alabama Huntsvile", "University of Alabama in Huntsville"},
+		{"auburn university", "Auburn University"},
+		{"St Louis Univ", "Saint Louis University"},
+		{"Athens State Univ", "Athens State University"},
+		{"birmingham southern", "Birmingham Southern College"},
+		{"chattahoochee valley", "Chattahoochee Valley Community College"},
+	}
+
+	for _, tt := range tests {
+		results, total := index.Search(tt.query, 5)
+		if total == 0 || len(results) == 0 {
+			t.Errorf("Search(%q) found nothing", tt.query)
+			continue
+		}
+		if results[0].Name != tt.want {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, results[0].Name, tt.want)
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].Score > results[i-1].Score {
+				t.Errorf("Search(%q) results are not ordered by score", tt.query)
+			}
+		}
+	}
+
+	if results, total := index.Search("qwxz", 5); total != 0 || len(results) != 0 {
+		t.Errorf("Search(%q) = %v, want no results", "qwxz", results)
+	}
+}
+
+func TestIndexedStoreKeepsIndexUpToDate(t *testing.T) {
+	index, store := newTestIndex(t)
+	ctx := context.Background()
+
+	added, err := store.AddSchool(ctx, db.School{Name: "Quixotic Institute of Technology"})
+	if err != nil {
+		t.Fatalf("AddSchool: %v", err)
+	}
+	if results, _ := index.Search("quixotic inst", 1); len(results) == 0 || results[0].ID != added.ID {
+		t.Fatalf("added school was not found: %v", results)
+	}
+
+	_, err = store.UpdateSchool(ctx, db.School{ID: added.ID, Name: "Zephyrine Academy"})
+	if err != nil {
+		t.Fatalf("UpdateSchool: %v", err)
+	}
+	if results, _ := index.Search("quixotic", 1); len(results) != 0 {
+		t.Errorf("old name is still indexed: %v", results)
+	}
+	if results, _ := index.Search("zephyrin acad", 1); len(results) == 0 || results[0].ID != added.ID {
+		t.Errorf("updated school was not found: %v", results)
+	}
+
+	if err := store.DeleteSchool(ctx, added.ID, 0); err != nil {
+		t.Fatalf("DeleteSchool: %v", err)
+	}
+	if results, _ := index.Search("zephyrine", 1); len(results) != 0 {
+		t.Errorf("deleted school is still indexed: %v", results)
+	}
+
+	if _, err := store.RestoreSchool(ctx, added.ID); err != nil {
+		t.Fatalf("RestoreSchool: %v", err)
+	}
+	if results, _ := index.Search("zephyrine", 1); len(results) == 0 || results[0].ID != added.ID {
+		t.Errorf("restored school was not found: %v", results)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	index := NewIndex()
+	if err := index.Load(context.Background(), db.NewMemoryStore()); err != nil {
+		b.Fatalf("Load: %v", err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		index.Search("Univ of Alabama Huntsvile", 10)
+	}
+}
+
+func TestIndexedStoreTransactions(t *testing.T) {
+	index, store := newTestIndex(t)
+	ctx := context.Background()
+
+	errRollback := errors.New("rollback")
+	err := store.InTransaction(ctx, func(tx db.SchoolStore) error {
+		if _, err := tx.AddSchool(ctx, db.School{Name: "Quasimodo Conservatory"}); err != nil {
+			return err
+		}
+		if results, _ := index.Search("quasimodo", 1); len(results) != 0 {
+			t.Errorf("school is indexed before the transaction commits: %v", results)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("InTransaction returned %v, want %v", err, errRollback)
+	}
+	if results, _ := index.Search("quasimodo", 1); len(results) != 0 {
+		t.Errorf("school added in a rolled back transaction is indexed: %v", results)
+	}
+
+	var added *db.School
+	err = store.InTransaction(ctx, func(tx db.SchoolStore) error {
+		added, err = tx.AddSchool(ctx, db.School{Name: "Quasimodo Conservatory"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTransaction: %v", err)
+	}
+	if results, _ := index.Search("quasimodo", 1); len(results) == 0 || results[0].ID != added.ID {
+		t.Errorf("school added in a committed transaction was not found: %v", results)
+	}
+
+	// A nested transaction that fails is rolled back on its own
+	err = store.InTransaction(ctx, func(tx db.SchoolStore) error {
+		tx.InTransaction(ctx, func(nested db.SchoolStore) error {
+			if _, err := nested.AddSchool(ctx, db.School{Name: "Esmeralda Academy"}); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		_, err := tx.AddSchool(ctx, db.School{Name: "Frollo Institute"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTransaction: %v", err)
+	}
+	if results, _ := index.Search("esmeralda", 1); len(results) != 0 {
+		t.Errorf("school added in a rolled back nested transaction is indexed: %v", results)
+	}
+	if results, _ := index.Search("frollo", 1); len(results) == 0 {
+		t.Errorf("school added after a rolled back nested transaction was not found")
+	}
+}
+
+func TestIndexedStoreConcurrentRenames(t *testing.T) {
+	index, store := newTestIndex(t)
+	ctx := context.Background()
+	added, err := store.AddSchool(ctx, db.School{Name: "Rename College 0"})
+	if err != nil {
+		t.Fatalf("AddSchool: %v", err)
+	}
+
+	// The index ends up with the name the store ends up with, whichever
+	// rename lands last
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.UpdateSchool(ctx, db.School{ID: added.ID, Name: fmt.Sprintf("Rename College %d", i)})
+		}(i)
+	}
+	wg.Wait()
+
+	school, err := store.GetSchool(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("GetSchool: %v", err)
+	}
+	index.mu.RLock()
+	indexed := index.docs[added.ID].name
+	index.mu.RUnlock()
+	if indexed != school.Name {
+		t.Errorf("index has the name %q, want %q", indexed, school.Name)
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	index, store := newTestIndex(t)
+
+	results := index.Autocomplete("Wallace", 20)
+	if len(results) == 0 {
+		t.Fatalf("Autocomplete(%q) found nothing", "Wallace")
+	}
+	for _, r := range results {
+		if !strings.Contains(r.Name, "Wallace") {
+			t.Errorf("Autocomplete(%q) returned %q", "Wallace", r.Name)
+		}
+	}
+
+	// Leading matches rank ahead of word start matches
+	results = index.Autocomplete("auburn", 20)
+	if len(results) < 2 || results[0].Name != "Auburn Career Center" {
+		t.Errorf("Autocomplete(%q) = %v, want leading matches first", "auburn", results)
+	}
+	leading := true
+	for _, r := range results {
+		starts := strings.HasPrefix(strings.ToLower(r.Name), "auburn")
+		if starts && !leading {
+			t.Errorf("Autocomplete(%q) returned leading match %q after a word start match", "auburn", r.Name)
+		}
+		leading = starts
+	}
+
+	if results := index.Autocomplete("saint ", 50); len(results) == 0 {
+		t.Errorf("Autocomplete(%q) found nothing", "saint ")
+	} else {
+		for _, r := range results {
+			if strings.Contains(strings.ToLower(r.Name), "saints") {
+				t.Errorf("Autocomplete(%q) returned %q", "saint ", r.Name)
+			}
+		}
+	}
+
+	if results := index.Autocomplete("a", 5); len(results) != 5 {
+		t.Errorf("Autocomplete(%q) returned %d results, want 5", "a", len(results))
+	}
+
+	ctx := context.Background()
+	added, err := store.AddSchool(ctx, db.School{Name: "Zanzibar Wallace College"})
+	if err != nil {
+		t.Fatalf("AddSchool: %v", err)
+	}
+	results = index.Autocomplete("zanzibar", 5)
+	if len(results) != 1 || results[0].ID != added.ID {
+		t.Errorf("Autocomplete(%q) = %v, want the added school", "zanzibar", results)
+	}
+	if err := store.DeleteSchool(ctx, added.ID, 0); err != nil {
+		t.Fatalf("DeleteSchool: %v", err)
+	}
+	if results := index.Autocomplete("zanzibar", 5); len(results) != 0 {
+		t.Errorf("Autocomplete(%q) = %v after delete, want nothing", "zanzibar", results)
+	}
+}
+
+func BenchmarkAutocomplete(b *testing.B) {
+	index := NewIndex()
+	if err := index.Load(context.Background(), db.NewMemoryStore()); err != nil {
+		b.Fatalf("Load: %v", err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		index.Autocomplete("wal", 10)
+	}
+}
@@ -0,0 +1,140 @@
+package search
+
+import (
+	"context"
+	"sync"
+
+	"github.com/clinstid/schools_api/db"
+)
+
+// IndexedStore wraps a db.SchoolStore and keeps an Index up to date with every
+// change made through it. Deleted schools are removed from the index and
+// restored schools are added back.
+type IndexedStore struct {
+	db.SchoolStore
+	index *Index
+
+	// mu is held across each change to the store and the update of the
+	// index that follows it, so that the index is updated in the same order
+	// as the store. It is shared with the stores bound to transactions,
+	// which don't take it since it is held for the whole transaction.
+	mu *sync.Mutex
+
+	// pending collects the index updates of the stores passed to the
+	// function given to InTransaction, which are only applied once the
+	// transaction commits. It is nil outside of transactions.
+	pending *[]func()
+}
+
+// update applies fn to the index, or queues it until the transaction commits
+// if the store is bound to one.
+func (s *IndexedStore) update(fn func()) {
+	if s.pending != nil {
+		*s.pending = append(*s.pending, fn)
+		return
+	}
+	fn()
+}
+
+// lock locks the store for a change unless it is bound to a transaction, which
+// holds the lock already, and returns the function that unlocks it.
+func (s *IndexedStore) lock() func() {
+	if s.pending != nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// NewIndexedStore returns an IndexedStore that updates index whenever schools
+// in store are changed. The index should already hold the schools in store,
+// see Index.Load.
+//
+// The index belongs to this process and only sees the changes made through
+// the IndexedStore. Changes made to the same SQL database by other instances
+// of the service or by the import command are not searchable until the index
+// is loaded again, which happens when the service restarts.
+func NewIndexedStore(store db.SchoolStore, index *Index) *IndexedStore {
+	return &IndexedStore{SchoolStore: store, index: index, mu: new(sync.Mutex)}
+}
+
+// AddSchool adds the school to the underlying store and the index.
+func (s *IndexedStore) AddSchool(ctx context.Context, school db.School) (*db.School, error) {
+	defer s.lock()()
+
+	added, err := s.SchoolStore.AddSchool(ctx, school)
+	if err != nil {
+		return nil, err
+	}
+	s.update(func() { s.index.Add(added.ID, added.Name) })
+	return added, nil
+}
+
+// UpdateSchool updates the school in the underlying store and the index.
+func (s *IndexedStore) UpdateSchool(ctx context.Context, school db.School) (*db.School, error) {
+	defer s.lock()()
+
+	updated, err := s.SchoolStore.UpdateSchool(ctx, school)
+	if err != nil {
+		return nil, err
+	}
+	s.update(func() { s.index.Add(updated.ID, updated.Name) })
+	return updated, nil
+}
+
+// DeleteSchool deletes the school from the underlying store and removes it
+// from the index.
+func (s *IndexedStore) DeleteSchool(ctx context.Context, id int, version int64) error {
+	defer s.lock()()
+
+	if err := s.SchoolStore.DeleteSchool(ctx, id, version); err != nil {
+		return err
+	}
+	s.update(func() { s.index.Remove(id) })
+	return nil
+}
+
+// RestoreSchool restores the school in the underlying store and adds it back
+// to the index.
+func (s *IndexedStore) RestoreSchool(ctx context.Context, id int) (*db.School, error) {
+	defer s.lock()()
+
+	restored, err := s.SchoolStore.RestoreSchool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.update(func() { s.index.Add(restored.ID, restored.Name) })
+	return restored, nil
+}
+
+// InTransaction runs fn in a transaction of the underlying store and updates
+// the index with the changes made by fn once the transaction commits.
+func (s *IndexedStore) InTransaction(ctx context.Context, fn func(tx db.SchoolStore) error) error {
+	if s.pending != nil {
+		// A nested transaction that fails is rolled back on its own by
+		// the underlying store, so its updates are dropped
+		n := len(*s.pending)
+		err := s.SchoolStore.InTransaction(ctx, func(tx db.SchoolStore) error {
+			return fn(&IndexedStore{SchoolStore: tx, index: s.index, mu: s.mu, pending: s.pending})
+		})
+		if err != nil {
+			*s.pending = (*s.pending)[:n]
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []func()
+	err := s.SchoolStore.InTransaction(ctx, func(tx db.SchoolStore) error {
+		return fn(&IndexedStore{SchoolStore: tx, index: s.index, mu: s.mu, pending: &pending})
+	})
+	if err != nil {
+		return err
+	}
+	for _, update := range pending {
+		update()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are dropped from names and queries because they carry no meaning
+// for matching ("University of Alabama" vs "Alabama University").
+var stopWords = map[string]bool{
+	"a":   true,
+	"and": true,
+	"at":  true,
+	"for": true,
+	"in":  true,
+	"of":  true,
+	"the": true,
+}
+
+// abbreviations maps common abbreviations in school names to the words they
+// may stand for. Both names and queries are expanded so that "St" matches
+// "State" and "Saint" and vice versa.
+var abbreviations = map[string][]string{
+	"acad": {"academy"},
+	"cmty": {"community"},
+	"coll": {"college"},
+	"col":  {"college"},
+	"comm": {"community"},
+	"ctr":  {"center"},
+	"ft":   {"fort"},
+	"inst": {"institute"},
+	"intl": {"international"},
+	"jr":   {"junior"},
+	"mt":   {"mount"},
+	"natl": {"national"},
+	"poly": {"polytechnic"},
+	"sch":  {"school"},
+	"sem":  {"seminary"},
+	"st":   {"state", "saint"},
+	"ste":  {"saint"},
+	"tech": {"technical", "technology"},
+	"u":    {"university"},
+	"uni":  {"university"},
+	"univ": {"university"},
+}
+
+// tokenize splits s in to lower case words, dropping punctuation and stop
+// words. If s only contains stop words they are kept.
+func tokenize(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(words))
+	for _, w := range words {
+		if !stopWords[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	if len(tokens) == 0 {
+		return words
+	}
+	return tokens
+}
+
+// expand returns token followed by the words it may be an abbreviation of.
+func expand(token string) []string {
+	return append([]string{token}, abbreviations[token]...)
+}
+
+// trigrams returns the distinct three letter substrings of term. Terms shorter
+// than three letters are their own only trigram.
+func trigrams(term string) []string {
+	runes := []rune(term)
+	if len(runes) < 3 {
+		return []string{term}
+	}
+
+	seen := make(map[string]bool, len(runes)-2)
+	grams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		g := string(runes[i : i+3])
+		if !seen[g] {
+			seen[g] = true
+			grams = append(grams, g)
+		}
+	}
+	return grams
+}
+
+// maxTypos returns the number of edits tolerated when matching a query term
+// of the specified length.
+func maxTypos(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// similarity scores how well the query term q matches the indexed term t
+// between 0 (no match) and 1 (identical). A query term that is a prefix of t
+// is treated as partially typed and terms within a few edits of each other
+// are treated as typos.
+func similarity(q, t string) float64 {
+	if q == t {
+		return 1
+	}
+
+	qr, tr := []rune(q), []rune(t)
+	if len(qr) >= 3 && strings.HasPrefix(t, q) {
+		return 0.75 + 0.2*float64(len(qr))/float64(len(tr))
+	}
+
+	typos := maxTypos(len(qr))
+	if typos == 0 {
+		return 0
+	}
+	diff := len(qr) - len(tr)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > typos {
+		return 0
+	}
+
+	d := editDistance(qr, tr)
+	if d > typos {
+		return 0
+	}
+	longest := len(qr)
+	if len(tr) > longest {
+		longest = len(tr)
+	}
+	return 1 - float64(d)/float64(longest)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -1,4 +1,5 @@
 from http import HTTPStatus
+import uuid
 
 from common import (
     TestSchoolsAPI,
@@ -27,3 +28,90 @@ class TestAddSchool(TestSchoolsAPI):
         response = self.add_school(name=new_name)
         assert response.status_code == HTTPStatus.BAD_REQUEST
         check_error_response(response, 'Field "name" must be a string')
+
+    def test_add_school_details(self):
+        details = {
+            'city': 'Auburn',
+            'state': 'AL',
+            'postal_code': '36849',
+            'country': 'US',
+            'type': 'public',
+            'level': '4-year',
+            'website': 'https://www.auburn.edu',
+            'external_id': '100858',
+        }
+        response = self.add_school(name='Detailed School', **details)
+        assert response.status_code == HTTPStatus.CREATED
+        school = response.json()
+        for field, value in details.items():
+            assert school.get(field) == value
+
+        response = self.get_school(school.get('id'))
+        assert response.status_code == HTTPStatus.OK
+        assert response.json() == school
+
+        response = self.list_schools_custom(params={'exact': 'Detailed School'})
+        assert school in response.json().get('schools')
+
+    def test_add_school_bad_details(self):
+        bad_details = [
+            ({'type': 'charter'}, 'Field "type" must be one of public, private, for-profit'),
+            ({'level': 'graduate'}, 'Field "level" must be one of 2-year, 4-year'),
+            ({'country': 'USA'}, 'Field "country" must be an ISO 3166-1 alpha-2 country code'),
+            ({'country': 'ZZ'}, 'Field "country" must be an ISO 3166-1 alpha-2 country code'),
+            ({'website': 'ftp://example.com'}, 'Field "website" must be an http or https URL'),
+            ({'postal_code': '1' * 21}, 'Field "postal_code" must be at most 20 characters'),
+        ]
+        for details, message in bad_details:
+            response = self.add_school(name='Bad Details School', **details)
+            assert response.status_code == HTTPStatus.BAD_REQUEST
+            check_error_response(response, message)
+
+    def test_add_school_missing_name(self):
+        response = self.add_school(name='', city='Auburn')
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'Field "name" is required')
+
+    def test_add_school_duplicate_name(self):
+        name = f'Duplicate Name {uuid.uuid4().hex} College'
+        existing = self.add_school(name=name).json()
+
+        response = self.add_school(name=f'  {name.upper()}. ')
+        assert response.status_code == HTTPStatus.CONFLICT
+        check_error_response(response, f'School with id {existing.get("id")} already has the name "{name}"')
+        body = response.json()
+        assert body.get('id') == existing.get('id')
+        assert body.get('link') == self.build_school_path(existing.get('id'))
+
+    def test_add_school_invalid_names(self):
+        bad_names = [
+            ('   ', 'Field "name" is required'),
+            ('a' * 256, 'Field "name" must be at most 255 characters'),
+            ('Line\nBreak College', 'Field "name" must not contain control characters'),
+        ]
+        for name, message in bad_names:
+            response = self.add_school(name=name)
+            assert response.status_code == HTTPStatus.BAD_REQUEST
+            check_error_response(response, message)
+            assert response.json().get('errors') == [{'field': 'name', 'message': message}]
+
+    def test_add_school_field_errors(self):
+        response = self.add_school(name=' ', type='charter')
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        assert response.json().get('errors') == [
+            {'field': 'name', 'message': 'Field "name" is required'},
+            {'field': 'type', 'message': 'Field "type" must be one of public, private, for-profit'},
+        ]
+
+    def test_add_school_trims_name(self):
+        name = f'Trimmed {uuid.uuid4().hex} College'
+        response = self.add_school(name=f'  {name}\t', city=' Auburn ')
+        assert response.status_code == HTTPStatus.CREATED
+        assert response.json().get('name') == name
+        assert response.json().get('city') == 'Auburn'
+
+    def test_add_school_trims_name_before_checking_length(self):
+        name = f'Padded {uuid.uuid4().hex} ' + 'a' * 200
+        response = self.add_school(name=f'{" " * 40}{name}{" " * 40}')
+        assert response.status_code == HTTPStatus.CREATED
+        assert response.json().get('name') == name
@@ -0,0 +1,41 @@
+from http import HTTPStatus
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestAutocompleteSchools(TestSchoolsAPI):
+    def test_autocomplete_schools_simple(self):
+        response = self.autocomplete_schools(prefix='Auburn Univ')
+        assert response.status_code == HTTPStatus.OK
+
+        suggestions = response.json().get('suggestions')
+        assert len(suggestions) > 0
+        for suggestion in suggestions:
+            for field in ('id', 'name'):
+                assert field in suggestion
+            assert suggestion.get('name').lower().startswith('auburn univ')
+
+    def test_autocomplete_schools_word_start(self):
+        response = self.autocomplete_schools(prefix='wallace', limit=100)
+        assert response.status_code == HTTPStatus.OK
+
+        names = [suggestion.get('name') for suggestion in response.json().get('suggestions')]
+        assert any(name.startswith('George C Wallace State') for name in names)
+
+    def test_autocomplete_schools_limit(self):
+        response = self.autocomplete_schools(prefix='a', limit=7)
+        assert response.status_code == HTTPStatus.OK
+        assert len(response.json().get('suggestions')) == 7
+
+    def test_autocomplete_schools_no_prefix(self):
+        response = self.autocomplete_schools(prefix='')
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'prefix query parameter is required')
+
+    def test_autocomplete_schools_bad_limit(self):
+        response = self.autocomplete_schools(prefix='a', limit=101)
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'limit query parameter must be at least 1 and no greater than 100')
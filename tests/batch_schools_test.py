@@ -0,0 +1,149 @@
+from http import HTTPStatus
+import uuid
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestBatchSchools(TestSchoolsAPI):
+    def add_unique_school(self, prefix):
+        response = self.add_school(name=f'{prefix} {uuid.uuid4().hex}')
+        assert response.status_code == HTTPStatus.CREATED
+        return response
+
+    def test_atomic_batch(self):
+        existing = self.add_unique_school('Batch Update')
+        to_delete = self.add_unique_school('Batch Delete')
+        new_name = f'Batch Create {uuid.uuid4().hex}'
+
+        response = self.batch_schools([
+            {'op': 'create', 'school': {'name': new_name, 'city': 'Auburn'}},
+            {
+                'op': 'update',
+                'id': existing.json().get('id'),
+                'if_match': existing.headers.get('ETag'),
+                'school': {'name': existing.json().get('name'), 'city': 'Montgomery'},
+            },
+            {'op': 'delete', 'id': to_delete.json().get('id')},
+        ])
+        assert response.status_code == HTTPStatus.OK
+        results = response.json().get('results')
+        assert [result.get('status') for result in results] == [HTTPStatus.CREATED, HTTPStatus.OK, HTTPStatus.NO_CONTENT]
+
+        created = results[0]
+        assert created.get('school').get('name') == new_name
+        fetched = self.get_school(created.get('id'))
+        assert fetched.status_code == HTTPStatus.OK
+        assert fetched.headers.get('ETag') == created.get('etag')
+
+        assert self.get_school(existing.json().get('id')).json().get('city') == 'Montgomery'
+        assert self.get_school(to_delete.json().get('id')).status_code == HTTPStatus.GONE
+
+    def test_atomic_batch_rolled_back(self):
+        existing = self.add_unique_school('Batch Rollback')
+        taken = self.add_unique_school('Batch Taken')
+        new_name = f'Batch Rolled Back {uuid.uuid4().hex}'
+
+        # Renaming the school to a taken name fails, so the school added
+        # before it is rolled back
+        response = self.batch_schools([
+            {'op': 'create', 'school': {'name': new_name}},
+            {'op': 'update', 'id': existing.json().get('id'), 'school': {'name': taken.json().get('name')}},
+        ], mode='atomic')
+        assert response.status_code == HTTPStatus.UNPROCESSABLE_ENTITY
+        check_error_response(response, 'operation 1 failed, no schools were changed')
+        results = response.json().get('results')
+        assert [result.get('status') for result in results] == [HTTPStatus.FAILED_DEPENDENCY, HTTPStatus.CONFLICT]
+        assert results[0].get('error').get('message') == 'not applied because operation 1 failed'
+        assert results[1].get('error').get('id') == taken.json().get('id')
+
+        schools = self.list_schools_custom(params={'exact': new_name}).json()
+        assert schools.get('meta').get('total') == 0
+
+    def test_atomic_batch_conflict_with_rolled_back_school(self):
+        name = f'Batch Conflict {uuid.uuid4().hex}'
+
+        # The school added by the first operation is rolled back, so the
+        # conflict with it does not refer to its id
+        response = self.batch_schools([
+            {'op': 'create', 'school': {'name': name}},
+            {'op': 'create', 'school': {'name': name}},
+        ], mode='atomic')
+        assert response.status_code == HTTPStatus.UNPROCESSABLE_ENTITY
+        result = response.json().get('results')[1]
+        assert result.get('status') == HTTPStatus.CONFLICT
+        assert result.get('error') == {'message': f'School created by operation 0 already has the name "{name}"'}
+
+    def test_atomic_batch_if_match_failed_after_rolled_back_update(self):
+        existing = self.add_unique_school('Batch Stale')
+        school_id = existing.json().get('id')
+        school = {'name': existing.json().get('name'), 'city': 'Mobile'}
+
+        # The second update fails against the version made by the first,
+        # which is rolled back, so no ETag is reported for it
+        response = self.batch_schools([
+            {'op': 'update', 'id': school_id, 'if_match': existing.headers.get('ETag'), 'school': school},
+            {'op': 'update', 'id': school_id, 'if_match': existing.headers.get('ETag'), 'school': school},
+        ], mode='atomic')
+        assert response.status_code == HTTPStatus.UNPROCESSABLE_ENTITY
+        result = response.json().get('results')[1]
+        assert result.get('status') == HTTPStatus.PRECONDITION_FAILED
+        assert 'etag' not in result
+        assert self.get_school(school_id).headers.get('ETag') == existing.headers.get('ETag')
+
+    def test_partial_batch(self):
+        taken = self.add_unique_school('Batch Taken')
+        new_name = f'Batch Partial {uuid.uuid4().hex}'
+
+        response = self.batch_schools([
+            {'op': 'create', 'school': {'name': taken.json().get('name')}},
+            {'op': 'create', 'school': {'name': new_name}},
+            {'op': 'delete', 'id': 999999999},
+        ], mode='partial')
+        assert response.status_code == HTTPStatus.OK
+        results = response.json().get('results')
+        assert [result.get('status') for result in results] == [HTTPStatus.CONFLICT, HTTPStatus.CREATED, HTTPStatus.NOT_FOUND]
+        assert results[0].get('error').get('id') == taken.json().get('id')
+        assert results[2].get('id') == 999999999
+
+        schools = self.list_schools_custom(params={'exact': new_name}).json()
+        assert schools.get('meta').get('total') == 1
+
+    def test_if_match_failed(self):
+        existing = self.add_unique_school('Batch If-Match')
+        etag = existing.headers.get('ETag')
+        school_id = existing.json().get('id')
+        assert self.update_school(school_id, name=existing.json().get('name'), city='Mobile').status_code == HTTPStatus.OK
+
+        response = self.batch_schools([{'op': 'delete', 'id': school_id, 'if_match': etag}])
+        assert response.status_code == HTTPStatus.UNPROCESSABLE_ENTITY
+        result = response.json().get('results')[0]
+        assert result.get('status') == HTTPStatus.PRECONDITION_FAILED
+        assert result.get('etag') == self.get_school(school_id).headers.get('ETag')
+
+    def test_invalid_batch(self):
+        response = self.batch_schools([], mode='atomic')
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+
+        response = self.batch_schools([{'op': 'update', 'school': {'name': 'Missing Id'}}], mode='partial')
+        assert response.status_code == HTTPStatus.OK
+        result = response.json().get('results')[0]
+        assert result.get('status') == HTTPStatus.BAD_REQUEST
+        assert 'id' not in result
+
+    def test_batch_retried(self):
+        name = f'Batch Idempotent {uuid.uuid4().hex}'
+        headers = {'Idempotency-Key': str(uuid.uuid4())}
+        operations = [{'op': 'create', 'school': {'name': name}}]
+
+        first = self.batch_schools(operations, headers=headers)
+        assert first.status_code == HTTPStatus.OK
+        retry = self.batch_schools(operations, headers=headers)
+        assert retry.status_code == HTTPStatus.OK
+        assert retry.headers.get('Idempotent-Replayed') == 'true'
+        assert retry.json() == first.json()
+
+        schools = self.list_schools_custom(params={'exact': name}).json()
+        assert schools.get('meta').get('total') == 1
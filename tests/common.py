@@ -1,3 +1,4 @@
+import json
 import os
 from urllib.parse import urlparse, parse_qs
 
@@ -74,11 +75,12 @@ class TestSchoolsAPI:
         )
         return response
 
-    def list_schools_custom(self, params=None):
+    def list_schools_custom(self, params=None, headers=None):
         """Make a request to the ListSchools operation with custom parameters
 
         params:
             params: Dictionary of parameters to pass directly into the requests.get call
+            headers: Extra request headers, such as Accept
 
         returns:
             A requests.Response object
@@ -86,29 +88,34 @@ class TestSchoolsAPI:
         response = requests.get(
             url=self.SCHOOLS_PATH,
             params=params,
+            headers=headers or {},
         )
         return response
 
-    def get_school(self, school_id):
+    def get_school(self, school_id, headers=None):
         """Make a request to the GetSchool operation
 
         params:
             school_id: The id of the school to retrieve
+            headers: Extra request headers, such as Accept
 
         returns:
             A requests.Response object
         """
         response = requests.get(
-            url=self.build_school_path(school_id)
+            url=self.build_school_path(school_id),
+            headers=headers or {},
         )
         return response
 
-    def update_school(self, school_id, name):
+    def update_school(self, school_id, name, headers=None, **fields):
         """Make a request to the UpdateSchool operation
 
         params:
             school_id: The id of the school to update
             name: The new name of the school
+            headers: Extra request headers, such as If-Match
+            fields: Other fields of the school, such as city or website
 
         returns:
             A requests.Response object
@@ -118,24 +125,179 @@ class TestSchoolsAPI:
             json={
                 'id': school_id,
                 'name': name,
-            }
+                **fields,
+            },
+            headers=headers or {},
         )
         return response
 
-    def add_school(self, name):
+    def patch_school(self, school_id, patch, content_type='application/merge-patch+json', headers=None):
+        """Make a request to the PatchSchool operation
+
+        params:
+            school_id: The id of the school to patch
+            patch: The patch document, serialized as JSON
+            content_type: The media type of the patch document
+            headers: Extra request headers, such as If-Match
+
+        returns:
+            A requests.Response object
+        """
+        response = requests.patch(
+            url=self.build_school_path(school_id),
+            data=json.dumps(patch),
+            headers={'Content-Type': content_type, **(headers or {})},
+        )
+        return response
+
+    def add_school(self, name, headers=None, **fields):
         """Make a request to the AddSchool operation
 
         params:
-            school_id: The id of the school to update
-            name: The new name of the school
+            name: The name of the new school
+            headers: Extra request headers, such as Idempotency-Key
+            fields: Other fields of the school, such as city or website
 
         returns:
             A requests.Response object
         """
         response = requests.post(
             url=self.SCHOOLS_PATH,
+            headers=headers or {},
             json={
                 'name': name,
+                **fields,
             }
         )
         return response
+
+    def delete_school(self, school_id, headers=None):
+        """Make a request to the DeleteSchool operation
+
+        params:
+            school_id: The id of the school to delete
+            headers: Extra request headers, such as If-Match
+
+        returns:
+            A requests.Response object
+        """
+        response = requests.delete(
+            url=self.build_school_path(school_id),
+            headers=headers or {},
+        )
+        return response
+
+    def restore_school(self, school_id):
+        """Make a request to the RestoreSchool operation
+
+        params:
+            school_id: The id of the school to restore
+
+        returns:
+            A requests.Response object
+        """
+        response = requests.post(
+            url=f'{self.build_school_path(school_id)}/restore'
+        )
+        return response
+
+    def search_schools(self, query, limit=None):
+        """Make a request to the SearchSchools operation
+
+        params:
+            query: The text to search for
+            limit: The maximum number of results to return
+
+        returns:
+            A requests.Response object
+        """
+        params = {'q': query}
+        if limit is not None:
+            params['limit'] = limit
+        response = requests.get(
+            url=f'{self.SCHOOLS_PATH}/search',
+            params=params,
+        )
+        return response
+
+    def autocomplete_schools(self, prefix, limit=None):
+        """Make a request to the AutocompleteSchools operation
+
+        params:
+            prefix: The partially typed name
+            limit: The maximum number of suggestions to return
+
+        returns:
+            A requests.Response object
+        """
+        params = {'prefix': prefix}
+        if limit is not None:
+            params['limit'] = limit
+        response = requests.get(
+            url=f'{self.SCHOOLS_PATH}/autocomplete',
+            params=params,
+        )
+        return response
+
+    def import_schools(self, data, content_type, upsert=None):
+        """Make a request to the ImportSchools operation
+
+        params:
+            data: The CSV or JSON Lines file to import as a string
+            content_type: The Content-Type of data
+            upsert: Whether to update schools with matching external ids
+
+        returns:
+            A requests.Response object
+        """
+        params = {}
+        if upsert is not None:
+            params['upsert'] = str(upsert).lower()
+        response = requests.post(
+            url=f'{self.SCHOOLS_PATH}/import',
+            params=params,
+            data=data.encode(),
+            headers={'Content-Type': content_type},
+        )
+        return response
+
+    def export_schools(self, export_format=None, headers=None):
+        """Make a request to the ExportSchools operation
+
+        params:
+            export_format: The format to export schools in
+            headers: Extra request headers, such as If-None-Match
+
+        returns:
+            A requests.Response object
+        """
+        params = {}
+        if export_format is not None:
+            params['format'] = export_format
+        response = requests.get(
+            url=f'{self.SCHOOLS_PATH}/export',
+            params=params,
+            headers=headers or {},
+        )
+        return response
+
+    def batch_schools(self, operations, mode=None, headers=None):
+        """Make a request to the BatchSchools operation
+
+        params:
+            operations: The create, update and delete operations to apply
+            mode: atomic or partial
+            headers: Extra request headers, such as Idempotency-Key
+
+        returns:
+            A requests.Response object
+        """
+        batch = {'operations': operations}
+        if mode is not None:
+            batch['mode'] = mode
+        response = requests.post(
+            url=f'{self.SCHOOLS_PATH}/batch',
+            headers=headers or {},
+            json=batch,
+        )
+        return response
@@ -0,0 +1,90 @@
+from http import HTTPStatus
+import uuid
+
+from common import TestSchoolsAPI
+
+
+class TestConditionalGet(TestSchoolsAPI):
+    def add_unique_school(self, prefix):
+        response = self.add_school(name=f'{prefix} {uuid.uuid4().hex}', city='Auburn')
+        assert response.status_code == HTTPStatus.CREATED
+        return response.json()
+
+    def test_get_school_if_none_match(self):
+        school = self.add_unique_school('If-None-Match')
+        response = self.get_school(school.get('id'))
+        assert response.status_code == HTTPStatus.OK
+        etag = response.headers.get('ETag')
+        assert response.headers.get('Last-Modified')
+        assert response.headers.get('Cache-Control') == 'no-cache'
+
+        response = self.get_school(school.get('id'), headers={'If-None-Match': etag})
+        assert response.status_code == HTTPStatus.NOT_MODIFIED
+        assert response.headers.get('ETag') == etag
+        assert response.content == b''
+
+        self.update_school(school.get('id'), name=school.get('name'), city='Montgomery')
+        response = self.get_school(school.get('id'), headers={'If-None-Match': etag})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('ETag') != etag
+        assert response.json().get('city') == 'Montgomery'
+
+    def test_get_school_etag_has_store_epoch(self):
+        school = self.add_unique_school('Epoch ETag')
+        response = self.get_school(school.get('id'))
+        etag = response.headers.get('ETag')
+        list_etag = self.list_schools_custom(params={'limit': 1}).headers.get('ETag')
+        epoch = list_etag.strip('"').split('-')[0]
+        assert etag == f'"{epoch}-1"'
+
+        # The version alone, as from a store that has been recreated, does
+        # not validate the response
+        response = self.get_school(school.get('id'), headers={'If-None-Match': '"1"'})
+        assert response.status_code == HTTPStatus.OK
+
+    def test_get_school_if_modified_since(self):
+        school = self.add_unique_school('If-Modified-Since')
+        last_modified = self.get_school(school.get('id')).headers.get('Last-Modified')
+
+        response = self.get_school(school.get('id'), headers={'If-Modified-Since': last_modified})
+        assert response.status_code == HTTPStatus.NOT_MODIFIED
+
+        response = self.get_school(school.get('id'), headers={'If-Modified-Since': 'Thu, 01 Jan 1970 00:00:00 GMT'})
+        assert response.status_code == HTTPStatus.OK
+
+    def test_get_school_etag_per_media_type(self):
+        school = self.add_unique_school('Media Type ETag')
+        json_etag = self.get_school(school.get('id')).headers.get('ETag')
+        csv_etag = self.get_school(school.get('id'), headers={'Accept': 'text/csv'}).headers.get('ETag')
+        assert csv_etag != json_etag
+
+        # The JSON ETag does not validate the CSV representation
+        response = self.get_school(school.get('id'), headers={'Accept': 'text/csv', 'If-None-Match': json_etag})
+        assert response.status_code == HTTPStatus.OK
+
+        # Either ETag can be used to update the school
+        response = self.update_school(school.get('id'), name=school.get('name'), headers={'If-Match': csv_etag})
+        assert response.status_code == HTTPStatus.OK
+
+    def test_list_schools_etag_changes(self):
+        params = {'limit': 1, 'sort': 'id'}
+        response = self.list_schools_custom(params=params)
+        assert response.status_code == HTTPStatus.OK
+        etag = response.headers.get('ETag')
+        last_modified = response.headers.get('Last-Modified')
+        assert etag and last_modified
+
+        response = self.list_schools_custom(params=params, headers={'If-None-Match': etag})
+        assert response.status_code == HTTPStatus.NOT_MODIFIED
+        response = self.list_schools_custom(params=params, headers={'If-Modified-Since': last_modified})
+        assert response.status_code == HTTPStatus.NOT_MODIFIED
+
+        # Other pages have other ETags
+        other = self.list_schools_custom(params={'limit': 2, 'sort': 'id'})
+        assert other.headers.get('ETag') != etag
+
+        # A change to any school, even one not on the page, changes the ETag
+        self.add_unique_school('Collection ETag')
+        response = self.list_schools_custom(params=params, headers={'If-None-Match': etag})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('ETag') != etag
@@ -0,0 +1,90 @@
+from http import HTTPStatus
+import csv
+import io
+import json
+import xml.etree.ElementTree as ET
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+NOT_ACCEPTABLE_MSG = (
+    'Accept header must allow one of application/json, text/csv, '
+    'application/xml, application/yaml, application/x-ndjson'
+)
+
+
+class TestContentNegotiation(TestSchoolsAPI):
+    def test_get_school_default_json(self):
+        response = self.get_school(1, headers={'Accept': '*/*'})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('Content-Type').startswith('application/json')
+        assert 'Accept' in response.headers.get('Vary')
+        assert response.json().get('id') == 1
+
+    def test_get_school_csv(self):
+        school = self.get_school(1).json()
+        response = self.get_school(1, headers={'Accept': 'text/csv'})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('Content-Type').startswith('text/csv')
+        rows = list(csv.DictReader(io.StringIO(response.text)))
+        assert len(rows) == 1
+        assert rows[0].get('id') == '1'
+        assert rows[0].get('name') == school.get('name')
+
+    def test_get_school_xml(self):
+        response = self.get_school(1, headers={'Accept': 'application/xml'})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('Content-Type').startswith('application/xml')
+        root = ET.fromstring(response.text)
+        assert root.tag == 'school'
+        assert root.findtext('id') == '1'
+
+    def test_get_school_yaml(self):
+        response = self.get_school(1, headers={'Accept': 'application/yaml'})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('Content-Type').startswith('application/yaml')
+        assert 'id: 1\n' in response.text
+
+    def test_get_school_quality_values(self):
+        accept = 'application/json;q=0.5, application/x-ndjson'
+        response = self.get_school(1, headers={'Accept': accept})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('Content-Type').startswith('application/x-ndjson')
+        assert json.loads(response.text).get('id') == 1
+
+    def test_get_school_not_acceptable(self):
+        response = self.get_school(1, headers={'Accept': 'text/html'})
+        assert response.status_code == HTTPStatus.NOT_ACCEPTABLE
+        check_error_response(response, NOT_ACCEPTABLE_MSG)
+
+    def test_list_schools_xml(self):
+        response = self.list_schools_custom(params={'limit': 5}, headers={'Accept': 'application/xml'})
+        assert response.status_code == HTTPStatus.OK
+        root = ET.fromstring(response.text)
+        assert root.tag == 'schools'
+        assert len(root.findall('school')) == 5
+        assert int(root.findtext('meta/total')) > 5
+        assert root.findtext('links/next')
+
+    def test_list_schools_ndjson(self):
+        response = self.list_schools_custom(params={'limit': 5}, headers={'Accept': 'application/x-ndjson'})
+        assert response.status_code == HTTPStatus.OK
+        lines = response.text.splitlines()
+        assert len(lines) == 5
+        assert all('id' in json.loads(line) for line in lines)
+        assert int(response.headers.get('X-Total-Count')) > 5
+        assert 'rel="next"' in response.headers.get('Link')
+
+    def test_list_schools_csv(self):
+        response = self.list_schools_custom(params={'limit': 5, 'cursor': ''}, headers={'Accept': 'text/csv'})
+        assert response.status_code == HTTPStatus.OK
+        rows = list(csv.DictReader(io.StringIO(response.text)))
+        assert len(rows) == 5
+        assert 'cursor=' in response.headers.get('Link')
+
+    def test_list_schools_not_acceptable(self):
+        response = self.list_schools_custom(headers={'Accept': 'image/png'})
+        assert response.status_code == HTTPStatus.NOT_ACCEPTABLE
+        check_error_response(response, NOT_ACCEPTABLE_MSG)
@@ -0,0 +1,86 @@
+from http import HTTPStatus
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestDeleteSchool(TestSchoolsAPI):
+    def test_delete_school_simple(self):
+        response = self.add_school(name='School To Delete')
+        assert response.status_code == HTTPStatus.CREATED
+        school_id = response.json().get('id')
+
+        response = self.delete_school(school_id=school_id)
+        assert response.status_code == HTTPStatus.NO_CONTENT
+        assert response.content == b''
+
+        response = self.get_school(school_id=school_id)
+        assert response.status_code == HTTPStatus.GONE
+        check_error_response(response, f'School with id {school_id} has been deleted')
+
+    def test_delete_school_twice(self):
+        school_id = self.add_school(name='School To Delete Twice').json().get('id')
+
+        response = self.delete_school(school_id=school_id)
+        assert response.status_code == HTTPStatus.NO_CONTENT
+
+        response = self.delete_school(school_id=school_id)
+        assert response.status_code == HTTPStatus.GONE
+
+    def test_delete_school_hidden_from_list(self):
+        school_id = self.add_school(name='Hidden School').json().get('id')
+        self.delete_school(school_id=school_id)
+
+        total = self.list_schools().json().get('meta').get('total')
+        response = self.list_schools(offset=total - 1, limit=1)
+        ids = [school.get('id') for school in response.json().get('schools')]
+        assert school_id not in ids
+
+        response = self.list_schools_custom(params={'deleted': 'true', 'limit': 100})
+        assert response.status_code == HTTPStatus.OK
+        deleted_total = response.json().get('meta').get('total')
+        response = self.list_schools_custom(params={
+            'deleted': 'true',
+            'offset': max(deleted_total - 100, 0),
+            'limit': 100,
+        })
+        deleted = [school for school in response.json().get('schools') if school.get('id') == school_id]
+        assert len(deleted) == 1
+        assert 'deleted_at' in deleted[0]
+        assert 'deleted=true' in response.json().get('links').get('first')
+
+    def test_list_schools_bad_deleted(self):
+        response = self.list_schools_custom(params={'deleted': 'maybe'})
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'deleted query parameter must be true or false')
+
+    def test_delete_school_keeps_other_ids(self):
+        first = self.add_school(name='First School').json()
+        second = self.add_school(name='Second School').json()
+        third = self.add_school(name='Third School').json()
+
+        response = self.delete_school(school_id=second.get('id'))
+        assert response.status_code == HTTPStatus.NO_CONTENT
+
+        for school in (first, third):
+            response = self.get_school(school_id=school.get('id'))
+            assert response.status_code == HTTPStatus.OK
+            assert response.json() == school
+
+        # Ids of deleted schools are never handed out again
+        fourth = self.add_school(name='Fourth School').json()
+        assert fourth.get('id') > third.get('id')
+
+    def test_delete_school_not_found(self):
+        bad_id = 1000000000
+        response = self.delete_school(school_id=bad_id)
+        assert response.status_code == HTTPStatus.NOT_FOUND
+        check_error_response(response, f'School with id {bad_id} not found')
+
+    def test_delete_school_invalid_id(self):
+        bad_id = 'notanumber'
+        response = self.delete_school(school_id=bad_id)
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'school id must be a number')
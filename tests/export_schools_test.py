@@ -0,0 +1,69 @@
+from http import HTTPStatus
+import csv
+import io
+import json
+import uuid
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestExportSchools(TestSchoolsAPI):
+    def test_export_schools_json(self):
+        response = self.export_schools()
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('Content-Type').startswith('application/json')
+        assert 'filename="schools.json"' in response.headers.get('Content-Disposition')
+
+        schools = response.json()
+        total = self.list_schools(limit=1).json().get('meta').get('total')
+        assert len(schools) == total
+        ids = [school.get('id') for school in schools]
+        assert ids == sorted(ids)
+
+    def test_export_schools_csv(self):
+        name = f'CSV Export {uuid.uuid4().hex}, Inc'
+        school_id = self.add_school(name, city='Auburn').json().get('id')
+
+        response = self.export_schools('csv')
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('Content-Type').startswith('text/csv')
+
+        rows = list(csv.DictReader(io.StringIO(response.text)))
+        exported = [row for row in rows if row.get('id') == str(school_id)]
+        assert len(exported) == 1
+        assert exported[0].get('name') == name
+        assert exported[0].get('city') == 'Auburn'
+
+    def test_export_schools_jsonl(self):
+        response = self.export_schools('jsonl')
+        assert response.status_code == HTTPStatus.OK
+        lines = response.text.splitlines()
+        assert len(lines) > 0
+        assert all('name' in json.loads(line) for line in lines)
+
+    def test_export_schools_etag(self):
+        response = self.export_schools('jsonl')
+        assert response.status_code == HTTPStatus.OK
+        etag = response.headers.get('ETag')
+        assert etag
+
+        response = self.export_schools('jsonl', headers={'If-None-Match': etag})
+        assert response.status_code == HTTPStatus.NOT_MODIFIED
+        assert response.text == ''
+
+        # The same data in another format is a different representation
+        response = self.export_schools('csv', headers={'If-None-Match': etag})
+        assert response.status_code == HTTPStatus.OK
+
+        self.add_school(f'ETag Export {uuid.uuid4().hex}')
+        response = self.export_schools('jsonl', headers={'If-None-Match': etag})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('ETag') != etag
+
+    def test_export_schools_bad_format(self):
+        response = self.export_schools('xlsx')
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'format query parameter must be one of csv, jsonl, json')
@@ -0,0 +1,56 @@
+from http import HTTPStatus
+import uuid
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestIdempotencyKey(TestSchoolsAPI):
+    def test_add_school_retried(self):
+        name = f'Idempotent University {uuid.uuid4().hex}'
+        headers = {'Idempotency-Key': str(uuid.uuid4())}
+
+        first = self.add_school(name=name, city='Auburn', headers=headers)
+        assert first.status_code == HTTPStatus.CREATED
+        assert 'Idempotent-Replayed' not in first.headers
+
+        # The retry gets the same response instead of adding a duplicate or
+        # being told the name is taken
+        retry = self.add_school(name=name, city='Auburn', headers=headers)
+        assert retry.status_code == HTTPStatus.CREATED
+        assert retry.headers.get('Idempotent-Replayed') == 'true'
+        assert retry.headers.get('Location') == first.headers.get('Location')
+        assert retry.headers.get('ETag') == first.headers.get('ETag')
+        assert retry.json() == first.json()
+
+        schools = self.list_schools_custom(params={'exact': name}).json()
+        assert schools.get('meta').get('total') == 1
+
+    def test_add_school_key_reused(self):
+        headers = {'Idempotency-Key': str(uuid.uuid4())}
+        first = self.add_school(name=f'Reused Key {uuid.uuid4().hex}', headers=headers)
+        assert first.status_code == HTTPStatus.CREATED
+
+        response = self.add_school(name=f'Other School {uuid.uuid4().hex}', headers=headers)
+        assert response.status_code == HTTPStatus.UNPROCESSABLE_ENTITY
+        check_error_response(response, 'Idempotency-Key has already been used for a different request')
+
+    def test_add_school_error_replayed(self):
+        name = f'Taken Name {uuid.uuid4().hex}'
+        self.add_school(name=name)
+
+        headers = {'Idempotency-Key': str(uuid.uuid4())}
+        first = self.add_school(name=name, headers=headers)
+        assert first.status_code == HTTPStatus.CONFLICT
+
+        retry = self.add_school(name=name, headers=headers)
+        assert retry.status_code == HTTPStatus.CONFLICT
+        assert retry.headers.get('Idempotent-Replayed') == 'true'
+        assert retry.json() == first.json()
+
+    def test_add_school_without_key(self):
+        name = f'No Key University {uuid.uuid4().hex}'
+        assert self.add_school(name=name).status_code == HTTPStatus.CREATED
+        assert self.add_school(name=name).status_code == HTTPStatus.CONFLICT
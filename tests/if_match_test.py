@@ -0,0 +1,78 @@
+from http import HTTPStatus
+import uuid
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestIfMatch(TestSchoolsAPI):
+    def add_unique_school(self, prefix):
+        response = self.add_school(name=f'{prefix} {uuid.uuid4().hex}', city='Auburn')
+        assert response.status_code == HTTPStatus.CREATED
+        return response
+
+    def test_etag_changes_with_school(self):
+        added = self.add_unique_school('ETag')
+        school_id = added.json().get('id')
+        etag = added.headers.get('ETag')
+        assert etag
+        assert self.get_school(school_id).headers.get('ETag') == etag
+
+        updated = self.update_school(school_id, name=added.json().get('name'), city='Montgomery')
+        assert updated.status_code == HTTPStatus.OK
+        assert updated.headers.get('ETag') not in (None, etag)
+        assert self.get_school(school_id).headers.get('ETag') == updated.headers.get('ETag')
+
+    def test_update_if_match(self):
+        added = self.add_unique_school('If-Match Update')
+        school = added.json()
+        etag = added.headers.get('ETag')
+
+        # The first editor wins and the second, still holding the old ETag,
+        # is told the school has changed instead of overwriting it
+        first = self.update_school(school.get('id'), name=school.get('name'), city='Montgomery', headers={'If-Match': etag})
+        assert first.status_code == HTTPStatus.OK
+        second = self.update_school(school.get('id'), name=school.get('name'), city='Mobile', headers={'If-Match': etag})
+        assert second.status_code == HTTPStatus.PRECONDITION_FAILED
+        check_error_response(second, 'If-Match does not match the current ETag of the school')
+        assert second.headers.get('ETag') == first.headers.get('ETag')
+        assert self.get_school(school.get('id')).json().get('city') == 'Montgomery'
+
+        # Any version matches *
+        response = self.update_school(school.get('id'), name=school.get('name'), headers={'If-Match': '*'})
+        assert response.status_code == HTTPStatus.OK
+
+    def test_patch_if_match(self):
+        added = self.add_unique_school('If-Match Patch')
+        school_id = added.json().get('id')
+        etag = added.headers.get('ETag')
+
+        response = self.patch_school(school_id, {'state': 'AL'}, headers={'If-Match': etag})
+        assert response.status_code == HTTPStatus.OK
+        assert response.headers.get('ETag') != etag
+
+        response = self.patch_school(school_id, {'state': 'GA'}, headers={'If-Match': etag})
+        assert response.status_code == HTTPStatus.PRECONDITION_FAILED
+        assert self.get_school(school_id).json().get('state') == 'AL'
+
+    def test_delete_if_match(self):
+        added = self.add_unique_school('If-Match Delete')
+        school_id = added.json().get('id')
+
+        response = self.delete_school(school_id, headers={'If-Match': '"0"'})
+        assert response.status_code == HTTPStatus.PRECONDITION_FAILED
+        assert self.get_school(school_id).status_code == HTTPStatus.OK
+
+        response = self.delete_school(school_id, headers={'If-Match': added.headers.get('ETag')})
+        assert response.status_code == HTTPStatus.NO_CONTENT
+
+        # Restoring the school counts as a change
+        restored = self.restore_school(school_id)
+        assert restored.headers.get('ETag') not in (None, added.headers.get('ETag'))
+
+    def test_if_match_missing_school(self):
+        bad_id = 1000000000
+        response = self.delete_school(bad_id, headers={'If-Match': '"1"'})
+        assert response.status_code == HTTPStatus.NOT_FOUND
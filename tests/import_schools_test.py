@@ -0,0 +1,73 @@
+from http import HTTPStatus
+import json
+import uuid
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestImportSchools(TestSchoolsAPI):
+    def find_schools(self, prefix):
+        response = self.list_schools_custom(params={'prefix': prefix, 'sort': 'name'})
+        assert response.status_code == HTTPStatus.OK
+        return response.json().get('schools')
+
+    def test_import_schools_csv(self):
+        prefix = f'CSV Import {uuid.uuid4().hex}'
+        data = (
+            'name,city,state,country,type,level,external_id\n'
+            f'{prefix} Academy,Auburn,AL,US,public,4-year,910001\n'
+            f'"{prefix} College, Inc",,,,for-profit,,\n'
+        )
+        response = self.import_schools(data, 'text/csv')
+        assert response.status_code == HTTPStatus.OK
+        assert response.json() == {'created': 2, 'updated': 0}
+
+        schools = self.find_schools(prefix)
+        assert [school.get('name') for school in schools] == [f'{prefix} Academy', f'{prefix} College, Inc']
+        assert schools[0].get('city') == 'Auburn'
+        assert schools[0].get('external_id') == '910001'
+
+    def test_import_schools_jsonl_upsert(self):
+        rows = [
+            {'name': 'JSONL Import University', 'external_id': '910002', 'level': '2-year'},
+            {'name': 'JSONL Import Institute', 'external_id': '910003'},
+        ]
+        data = '\n'.join(json.dumps(row) for row in rows) + '\n'
+        response = self.import_schools(data, 'application/x-ndjson', upsert=True)
+        assert response.status_code == HTTPStatus.OK
+
+        rows[0]['level'] = '4-year'
+        data = '\n'.join(json.dumps(row) for row in rows) + '\n'
+        response = self.import_schools(data, 'application/x-ndjson', upsert=True)
+        assert response.status_code == HTTPStatus.OK
+        assert response.json() == {'created': 0, 'updated': 2}
+
+        schools = self.find_schools('JSONL Import')
+        assert len(schools) == 2
+        assert schools[1].get('level') == '4-year'
+
+    def test_import_schools_invalid_rows(self):
+        data = (
+            'name,type\n'
+            'Atomic Import College,public\n'
+            'Atomic Import University,charter\n'
+            ',private\n'
+        )
+        response = self.import_schools(data, 'text/csv')
+        assert response.status_code == HTTPStatus.UNPROCESSABLE_ENTITY
+        check_error_response(response, 'import has invalid rows, no schools were changed')
+        assert response.json().get('errors') == [
+            {'line': 3, 'field': 'type', 'message': 'Field "type" must be one of public, private, for-profit'},
+            {'line': 4, 'field': 'name', 'message': 'Field "name" is required'},
+        ]
+
+        # None of the rows were applied, including the valid one
+        assert self.find_schools('Atomic Import') == []
+
+    def test_import_schools_unsupported_type(self):
+        response = self.import_schools('{"name": "A"}', 'application/json')
+        assert response.status_code == HTTPStatus.UNSUPPORTED_MEDIA_TYPE
+        check_error_response(response, 'Content-Type must be text/csv, application/x-ndjson or application/jsonl')
@@ -1,4 +1,5 @@
 from http import HTTPStatus
+from urllib.parse import urlparse, parse_qs
 
 from common import (
     TestSchoolsAPI,
@@ -62,3 +63,140 @@ class TestListSchools(TestSchoolsAPI):
         check_page_link(links, 'prev', offset-limit, limit)
         check_page_link(links, 'first', 0, limit)
         check_page_link(links, 'last', (total // limit) * limit , limit)
+
+    def test_list_schools_query_filter(self):
+        response = self.list_schools_custom(params={'q': 'AUBURN UNIV', 'limit': 5})
+        assert response.status_code == HTTPStatus.OK
+
+        schools_collection = response.json()
+        schools = schools_collection.get('schools')
+        assert len(schools) > 0
+        for school in schools:
+            assert 'auburn univ' in school.get('name').lower()
+
+        names = [school.get('name') for school in schools]
+        assert 'Auburn University' in names
+
+        total = schools_collection.get('meta').get('total')
+        assert total < 100
+
+        links = schools_collection.get('links')
+        for link_name in ('first', 'last'):
+            query = parse_qs(urlparse(links.get(link_name)).query)
+            assert query.get('q') == ['AUBURN UNIV']
+
+    def test_list_schools_prefix_filter(self):
+        response = self.list_schools_custom(params={'prefix': 'university of alabama'})
+        assert response.status_code == HTTPStatus.OK
+
+        schools = response.json().get('schools')
+        assert len(schools) > 0
+        for school in schools:
+            assert school.get('name').lower().startswith('university of alabama')
+
+    def test_list_schools_exact_filter(self):
+        response = self.list_schools_custom(params={'exact': 'auburn university'})
+        assert response.status_code == HTTPStatus.OK
+
+        schools_collection = response.json()
+        assert schools_collection.get('meta').get('total') == 1
+        assert schools_collection.get('schools')[0].get('name') == 'Auburn University'
+
+    def test_list_schools_filter_no_matches(self):
+        response = self.list_schools_custom(params={'q': 'no school has this name'})
+        assert response.status_code == HTTPStatus.OK
+
+        schools_collection = response.json()
+        assert schools_collection.get('schools') == []
+        assert schools_collection.get('meta').get('total') == 0
+
+    def test_list_schools_filter_wildcards_are_literal(self):
+        response = self.list_schools_custom(params={'q': '%'})
+        assert response.status_code == HTTPStatus.OK
+        assert response.json().get('meta').get('total') == 0
+
+    def test_list_schools_sort_by_name(self):
+        response = self.list_schools_custom(params={'prefix': 'auburn', 'sort': 'name'})
+        assert response.status_code == HTTPStatus.OK
+
+        names = [school.get('name') for school in response.json().get('schools')]
+        assert len(names) > 1
+        assert names == sorted(names, key=str.lower)
+
+        links = response.json().get('links')
+        for link_name in ('first', 'last'):
+            query = parse_qs(urlparse(links.get(link_name)).query)
+            assert query.get('sort') == ['name']
+
+    def test_list_schools_sort_descending(self):
+        response = self.list_schools_custom(params={'sort': '-id', 'limit': 10})
+        assert response.status_code == HTTPStatus.OK
+
+        ids = [school.get('id') for school in response.json().get('schools')]
+        assert len(ids) == 10
+        assert ids == sorted(ids, reverse=True)
+
+    def test_list_schools_bad_sort(self):
+        response = self.list_schools_custom(params={'sort': 'state'})
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'sort query parameter must be one of id, -id, name, -name')
+
+    def test_list_schools_cursor_pagination(self):
+        params = {'prefix': 'university of', 'sort': 'name', 'limit': 5, 'cursor': ''}
+        response = self.list_schools_custom(params=params)
+        assert response.status_code == HTTPStatus.OK
+
+        schools_collection = response.json()
+        total = schools_collection.get('meta').get('total')
+        ids = [school.get('id') for school in schools_collection.get('schools')]
+        while schools_collection.get('meta').get('next_cursor'):
+            links = schools_collection.get('links')
+            assert 'prev' not in links and 'last' not in links
+            query = parse_qs(urlparse(links.get('next')).query)
+            assert 'offset' not in query
+            assert query.get('cursor') == [schools_collection.get('meta').get('next_cursor')]
+
+            params['cursor'] = query.get('cursor')[0]
+            response = self.list_schools_custom(params=params)
+            assert response.status_code == HTTPStatus.OK
+            schools_collection = response.json()
+            ids += [school.get('id') for school in schools_collection.get('schools')]
+
+        assert 'next' not in schools_collection.get('links')
+        assert len(ids) == total
+        assert len(set(ids)) == len(ids)
+
+    def test_list_schools_cursor_not_shifted_by_additions(self):
+        params = {'sort': '-id', 'limit': 3, 'cursor': ''}
+        first_page = self.list_schools_custom(params=params).json()
+        first_ids = [school.get('id') for school in first_page.get('schools')]
+
+        added = self.add_school('Cursor Pagination University')
+        assert added.status_code == HTTPStatus.CREATED
+        try:
+            params['cursor'] = first_page.get('meta').get('next_cursor')
+            response = self.list_schools_custom(params=params)
+            assert response.status_code == HTTPStatus.OK
+            next_ids = [school.get('id') for school in response.json().get('schools')]
+            assert len(next_ids) == 3
+            assert max(next_ids) < min(first_ids)
+        finally:
+            self.delete_school(added.json().get('id'))
+
+    def test_list_schools_bad_cursor(self):
+        response = self.list_schools_custom(params={'cursor': 'not-a-cursor'})
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'cursor query parameter is not a valid cursor')
+
+    def test_list_schools_cursor_for_other_sort(self):
+        response = self.list_schools_custom(params={'sort': 'name', 'limit': 1, 'cursor': ''})
+        next_cursor = response.json().get('meta').get('next_cursor')
+
+        response = self.list_schools_custom(params={'sort': 'id', 'cursor': next_cursor})
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'cursor query parameter was issued for a different sort')
+
+    def test_list_schools_cursor_with_offset(self):
+        response = self.list_schools_custom(params={'cursor': '', 'offset': 0})
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'cursor and offset query parameters cannot be combined')
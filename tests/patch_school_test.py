@@ -0,0 +1,104 @@
+from http import HTTPStatus
+import uuid
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+JSON_PATCH = 'application/json-patch+json'
+
+
+class TestPatchSchool(TestSchoolsAPI):
+    def test_patch_school_merge(self):
+        added = self.add_school(name=f'Merge Patch {uuid.uuid4().hex}', city='Auburn', type='public').json()
+
+        response = self.patch_school(added.get('id'), {'state': 'AL', 'type': None})
+        assert response.status_code == HTTPStatus.OK
+        school = response.json()
+
+        # Fields left out of the patch keep their values and null clears a field
+        assert school.get('name') == added.get('name')
+        assert school.get('city') == 'Auburn'
+        assert school.get('state') == 'AL'
+        assert 'type' not in school
+        assert self.get_school(added.get('id')).json() == school
+
+    def test_patch_school_json_patch(self):
+        added = self.add_school(name=f'JSON Patch {uuid.uuid4().hex}', city='Auburn').json()
+
+        patch = [
+            {'op': 'test', 'path': '/city', 'value': 'Auburn'},
+            {'op': 'replace', 'path': '/city', 'value': 'Montgomery'},
+            {'op': 'add', 'path': '/level', 'value': '4-year'},
+        ]
+        response = self.patch_school(added.get('id'), patch, content_type=JSON_PATCH)
+        assert response.status_code == HTTPStatus.OK
+        school = response.json()
+        assert school.get('city') == 'Montgomery'
+        assert school.get('level') == '4-year'
+        assert school.get('name') == added.get('name')
+
+    def test_patch_school_test_failed(self):
+        added = self.add_school(name=f'Failed Test {uuid.uuid4().hex}', city='Auburn').json()
+
+        patch = [
+            {'op': 'replace', 'path': '/city', 'value': 'Montgomery'},
+            {'op': 'test', 'path': '/name', 'value': 'Another Name'},
+        ]
+        response = self.patch_school(added.get('id'), patch, content_type=JSON_PATCH)
+        assert response.status_code == HTTPStatus.UNPROCESSABLE_ENTITY
+
+        # None of the operations are applied
+        assert self.get_school(added.get('id')).json() == added
+
+    def test_patch_school_invalid(self):
+        added = self.add_school(name=f'Invalid Patch {uuid.uuid4().hex}').json()
+
+        response = self.patch_school(added.get('id'), {'name': None, 'type': 'charter'})
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'Field "name" is required')
+        assert [e.get('field') for e in response.json().get('errors')] == ['name', 'type']
+
+        response = self.patch_school(added.get('id'), ['not', 'an', 'object'])
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'merge patch must be a JSON object')
+
+        response = self.patch_school(added.get('id'), [{'op': 'rename', 'path': '/name'}], content_type=JSON_PATCH)
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+
+        assert self.get_school(added.get('id')).json() == added
+
+    def test_patch_school_duplicate_name(self):
+        first = self.add_school(name=f'Patch Source {uuid.uuid4().hex}').json()
+        second = self.add_school(name=f'Patch Target {uuid.uuid4().hex}').json()
+
+        response = self.patch_school(first.get('id'), {'name': second.get('name').upper()})
+        assert response.status_code == HTTPStatus.CONFLICT
+        assert response.json().get('id') == second.get('id')
+
+    def test_patch_school_unsupported_type(self):
+        response = self.patch_school(0, {'city': 'Auburn'}, content_type='application/json')
+        assert response.status_code == HTTPStatus.UNSUPPORTED_MEDIA_TYPE
+        check_error_response(
+            response,
+            'Content-Type must be application/merge-patch+json or application/json-patch+json',
+        )
+
+    def test_patch_school_not_found(self):
+        bad_id = 1000000000
+        response = self.patch_school(bad_id, {'city': 'Auburn'})
+        assert response.status_code == HTTPStatus.NOT_FOUND
+        check_error_response(response, f'School with id {bad_id} not found')
+
+    def test_patch_school_deleted(self):
+        added = self.add_school(name=f'Deleted Patch {uuid.uuid4().hex}').json()
+        self.delete_school(added.get('id'))
+
+        response = self.patch_school(added.get('id'), {'city': 'Auburn'})
+        assert response.status_code == HTTPStatus.GONE
+
+    def test_patch_school_invalid_id(self):
+        response = self.patch_school('notanumber', {'city': 'Auburn'})
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'school id must be a number')
@@ -0,0 +1,40 @@
+from http import HTTPStatus
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestRestoreSchool(TestSchoolsAPI):
+    def test_restore_school_simple(self):
+        school = self.add_school(name='School To Restore').json()
+        school_id = school.get('id')
+        response = self.delete_school(school_id=school_id)
+        assert response.status_code == HTTPStatus.NO_CONTENT
+
+        response = self.restore_school(school_id=school_id)
+        assert response.status_code == HTTPStatus.OK
+        assert response.json() == school
+
+        response = self.get_school(school_id=school_id)
+        assert response.status_code == HTTPStatus.OK
+        assert response.json() == school
+
+    def test_restore_school_not_deleted(self):
+        school = self.add_school(name='School Not Deleted').json()
+        response = self.restore_school(school_id=school.get('id'))
+        assert response.status_code == HTTPStatus.OK
+        assert response.json() == school
+
+    def test_restore_school_not_found(self):
+        bad_id = 1000000000
+        response = self.restore_school(school_id=bad_id)
+        assert response.status_code == HTTPStatus.NOT_FOUND
+        check_error_response(response, f'School with id {bad_id} not found')
+
+    def test_restore_school_invalid_id(self):
+        bad_id = 'notanumber'
+        response = self.restore_school(school_id=bad_id)
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'school id must be a number')
@@ -0,0 +1,60 @@
+from http import HTTPStatus
+
+from common import (
+    TestSchoolsAPI,
+    check_error_response,
+)
+
+
+class TestSearchSchools(TestSchoolsAPI):
+    def test_search_schools_simple(self):
+        response = self.search_schools(query='Univ of Alabama Huntsvile')
+        assert response.status_code == HTTPStatus.OK
+
+        body = response.json()
+        for field in ('results', 'meta'):
+            assert field in body
+
+        results = body.get('results')
+        assert len(results) > 0
+        for field in ('id', 'name', 'score'):
+            assert field in results[0]
+        assert results[0].get('name') == 'University of Alabama in Huntsville'
+
+        scores = [result.get('score') for result in results]
+        assert scores == sorted(scores, reverse=True)
+        for score in scores:
+            assert 0 < score <= 1
+
+    def test_search_schools_limit(self):
+        response = self.search_schools(query='community college', limit=3)
+        assert response.status_code == HTTPStatus.OK
+
+        body = response.json()
+        assert len(body.get('results')) == 3
+        assert body.get('meta').get('total') > 3
+
+    def test_search_schools_sees_changes(self):
+        school = self.add_school(name='Xylophonic Conservatory').json()
+        school_id = school.get('id')
+
+        response = self.search_schools(query='xylophonik conservatory')
+        results = response.json().get('results')
+        assert results[0].get('id') == school_id
+
+        self.update_school(school_id=school_id, name='Quetzal Conservatory')
+        response = self.search_schools(query='xylophonic')
+        assert school_id not in [result.get('id') for result in response.json().get('results')]
+
+        response = self.search_schools(query='quetzal')
+        assert response.json().get('results')[0].get('id') == school_id
+
+    def test_search_schools_no_query(self):
+        response = self.search_schools(query='')
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'q query parameter is required')
+
+    def test_search_schools_bad_limit(self):
+        response = self.search_schools(query='auburn', limit=0)
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'limit query parameter must be at least 1 and no greater than 100')
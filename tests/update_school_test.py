@@ -1,4 +1,5 @@
 from http import HTTPStatus
+import uuid
 
 from common import (
     TestSchoolsAPI,
@@ -28,3 +29,34 @@ class TestUpdateSchool(TestSchoolsAPI):
         response = self.update_school(school_id=bad_id, name="invalid id name")
         assert response.status_code == HTTPStatus.BAD_REQUEST
         check_error_response(response, 'school id must be a number')
+
+    def test_update_school_details(self):
+        added = self.add_school(name='Details To Update', city='Auburn', type='public').json()
+
+        response = self.update_school(school_id=added.get('id'), name='Details Updated', level='2-year')
+        assert response.status_code == HTTPStatus.OK
+        school = response.json()
+        assert school.get('level') == '2-year'
+
+        # PUT replaces the whole school, clearing the fields left out
+        assert 'city' not in school
+        assert 'type' not in school
+        assert self.get_school(added.get('id')).json() == school
+
+    def test_update_school_duplicate_name(self):
+        first = self.add_school(name=f'Rename Source {uuid.uuid4().hex}').json()
+        second = self.add_school(name=f'Rename Target {uuid.uuid4().hex}').json()
+
+        response = self.update_school(school_id=first.get('id'), name=second.get('name').lower())
+        assert response.status_code == HTTPStatus.CONFLICT
+        assert response.json().get('id') == second.get('id')
+        assert response.json().get('link') == self.build_school_path(second.get('id'))
+
+        # Keeping the same name is not a conflict
+        response = self.update_school(school_id=first.get('id'), name=first.get('name'), city='Auburn')
+        assert response.status_code == HTTPStatus.OK
+
+    def test_update_school_invalid_name(self):
+        response = self.update_school(school_id=0, name='a' * 256)
+        assert response.status_code == HTTPStatus.BAD_REQUEST
+        check_error_response(response, 'Field "name" must be at most 255 characters')
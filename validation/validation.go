@@ -0,0 +1,189 @@
+// Package validation checks request payloads against the rules declared in
+// the binding tags of the resources types. Install makes gin apply the same
+// rules in the same way when binding request bodies, so payloads that do not
+// come from a request body, such as the rows of an import, are held to the
+// same standard.
+//
+// Payloads with a Normalize method, such as resources.School, are normalized
+// before they are checked, so that for example a name made up of spaces is
+// trimmed to nothing and rejected as missing.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Normalizer is implemented by payloads that are cleaned up, for example by
+// trimming whitespace, before they are validated.
+type Normalizer interface {
+	Normalize()
+}
+
+// structValidator is the binding.StructValidator set by Install. It
+// normalizes payloads before checking them with validate.
+type structValidator struct{}
+
+func (v structValidator) ValidateStruct(obj interface{}) error {
+	return Struct(obj)
+}
+
+func (v structValidator) Engine() interface{} {
+	return validate
+}
+
+// normalize calls the Normalize method of the payload in v, or of each
+// element of v if it is a slice or array of payloads.
+func normalize(v reflect.Value) {
+	if !v.IsValid() {
+		return
+	}
+	if n, ok := v.Interface().(Normalizer); ok && v.Kind() == reflect.Ptr && !v.IsNil() {
+		n.Normalize()
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			normalize(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if elem := v.Index(i); elem.CanAddr() {
+				normalize(elem.Addr())
+			}
+		}
+	}
+}
+
+// validate checks payloads against the rules in their binding tags. It
+// reports the JSON names of fields that fail validation rather than the names
+// of the struct fields.
+var validate = newValidate()
+
+func newValidate() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	})
+	v.RegisterValidation("nocontrol", noControl)
+	return v
+}
+
+// Install makes gin normalize request bodies and check them with the same
+// rules as Struct when binding them. It replaces binding.Validator, which is
+// shared by every gin engine in the process.
+func Install() {
+	binding.Validator = structValidator{}
+}
+
+// noControl checks that a string does not contain control characters, such
+// as newlines, tabs or NUL.
+func noControl(fl validator.FieldLevel) bool {
+	return strings.IndexFunc(fl.Field().String(), unicode.IsControl) < 0
+}
+
+// Struct normalizes v, a pointer to a struct or to a slice of them, if it is a
+// Normalizer and checks it against the rules in its binding tags.
+func Struct(v interface{}) error {
+	normalize(reflect.ValueOf(v))
+	return check(v)
+}
+
+// check checks obj, a struct, a pointer to one or a slice of them, the way
+// gin's default validator does.
+func check(obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.Elem().Kind() != reflect.Struct {
+			return check(value.Elem().Interface())
+		}
+		return validate.Struct(obj)
+	case reflect.Struct:
+		return validate.Struct(obj)
+	case reflect.Slice, reflect.Array:
+		var errs binding.SliceValidationError
+		for i := 0; i < value.Len(); i++ {
+			if err := check(value.Index(i).Interface()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+	}
+	return nil
+}
+
+// FieldError describes a field that failed validation.
+type FieldError struct {
+	// Field is the JSON name of the field.
+	Field string
+
+	Message string
+}
+
+// Fields describes every field that failed validation in err, in the order
+// of the fields in the payload. It returns nil if err is not a validation
+// error.
+func Fields(err error) []FieldError {
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil
+	}
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{Field: fe.Field(), Message: describe(fe)})
+	}
+	return fields
+}
+
+// Field returns the JSON name of the first field that failed validation in
+// err, or an empty string if err is not a validation error.
+func Field(err error) string {
+	if errs, ok := err.(validator.ValidationErrors); ok && len(errs) > 0 {
+		return errs[0].Field()
+	}
+	return ""
+}
+
+// Message describes the first rule that failed validation in err. Errors that
+// are not validation errors are described by their Error method.
+func Message(err error) string {
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok || len(errs) == 0 {
+		return err.Error()
+	}
+	return describe(errs[0])
+}
+
+// describe returns a message describing the rule that fe failed.
+func describe(fe validator.FieldError) string {
+	var rule string
+	switch fe.Tag() {
+	case "required":
+		rule = "is required"
+	case "max":
+		rule = fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "oneof":
+		rule = "must be one of " + strings.Replace(fe.Param(), " ", ", ", -1)
+	case "iso3166_1_alpha2":
+		rule = "must be an ISO 3166-1 alpha-2 country code"
+	case "http_url":
+		rule = "must be an http or https URL"
+	case "printascii":
+		rule = "must only contain printable ASCII characters"
+	case "nocontrol":
+		rule = "must not contain control characters"
+	default:
+		rule = "is invalid"
+	}
+	return fmt.Sprintf("Field %q %s", fe.Field(), rule)
+}
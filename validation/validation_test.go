@@ -0,0 +1,108 @@
+package validation
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/clinstid/schools_api/resources"
+)
+
+func TestSchool(t *testing.T) {
+	tests := []struct {
+		name   string
+		school resources.School
+		want   []FieldError
+	}{
+		{
+			name:   "valid",
+			school: resources.School{Name: strings.Repeat("é", 255)},
+		},
+		{
+			name:   "missing name",
+			school: resources.School{},
+			want:   []FieldError{{Field: "name", Message: `Field "name" is required`}},
+		},
+		{
+			name:   "whitespace name",
+			school: resources.School{Name: " \t\n "},
+			want:   []FieldError{{Field: "name", Message: `Field "name" is required`}},
+		},
+		{
+			name:   "long name",
+			school: resources.School{Name: strings.Repeat("a", 256)},
+			want:   []FieldError{{Field: "name", Message: `Field "name" must be at most 255 characters`}},
+		},
+		{
+			name:   "control characters",
+			school: resources.School{Name: "Line\nBreak College", City: "Nul\x00"},
+			want: []FieldError{
+				{Field: "name", Message: `Field "name" must not contain control characters`},
+				{Field: "city", Message: `Field "city" must not contain control characters`},
+			},
+		},
+		{
+			name:   "several fields",
+			school: resources.School{Name: " ", Type: "charter"},
+			want: []FieldError{
+				{Field: "name", Message: `Field "name" is required`},
+				{Field: "type", Message: `Field "type" must be one of public, private, for-profit`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			school := tt.school
+			err := Struct(&school)
+			if got := Fields(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fields(Struct(%+v)) = %+v, want %+v", tt.school, got, tt.want)
+			}
+			if (err == nil) != (tt.want == nil) {
+				t.Errorf("Struct returned %v", err)
+			}
+		})
+	}
+}
+
+func TestSchoolIsTrimmed(t *testing.T) {
+	school := resources.School{Name: "  Trimmed College \n", City: " Auburn "}
+	if err := Struct(&school); err != nil {
+		t.Fatalf("Struct: %v", err)
+	}
+	if school.Name != "Trimmed College" || school.City != "Auburn" {
+		t.Errorf("Struct left the school as %+v", school)
+	}
+}
+
+func TestSlicesAreNormalized(t *testing.T) {
+	schools := []resources.School{{Name: " A "}, {Name: " B "}}
+	if err := Struct(&schools); err != nil {
+		t.Fatalf("Struct: %v", err)
+	}
+	if schools[0].Name != "A" || schools[1].Name != "B" {
+		t.Errorf("Struct left the schools as %+v", schools)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	if _, ok := binding.Validator.(structValidator); ok {
+		t.Fatalf("binding.Validator was replaced before Install was called")
+	}
+	defer func(v binding.StructValidator) { binding.Validator = v }(binding.Validator)
+	Install()
+
+	// gin normalizes the body and reports the JSON names of its fields
+	req := httptest.NewRequest("POST", "/schools", strings.NewReader(`{"name": " ", "city": " Auburn "}`))
+	var school resources.School
+	err := binding.JSON.Bind(req, &school)
+	if field := Field(err); field != "name" {
+		t.Errorf("Bind returned %v, want an error for name", err)
+	}
+	if school.City != "Auburn" {
+		t.Errorf("Bind left the city as %q", school.City)
+	}
+}